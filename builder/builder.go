@@ -0,0 +1,508 @@
+// Package builder builds workshops: it fetches the theme and the content,
+// generates the Hugo site from the workshop config and runs Hugo. The dscda
+// commands are thin wrappers around Build and DryRun, so other tools can build
+// workshops in-process the same way.
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"workshop-builder/util"
+
+	"github.com/gohugoio/hugo/commands"
+	"github.com/spf13/afero"
+)
+
+// Logger receives the messages a build prints. *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Options describe the workshop to build and where. Relative paths are
+// resolved against WorkDir.
+type Options struct {
+	// WorkDir holds the workshop: its config, dscda.lock, manifest.yml, the
+	// content and theme checkouts and the theme cache. Defaults to the
+	// working directory.
+	WorkDir string
+	// ConfigPath is the workshop config, found in WorkDir when empty.
+	ConfigPath string
+	// Config is built instead of the config at ConfigPath when set.
+	Config *util.WorkshopConfig
+	// Environment selects the environment settings of the config that
+	// override its defaults, none when empty.
+	Environment string
+
+	// ThemeSource and ContentSource replace the sources of the config when
+	// set.
+	ThemeSource   *util.SourceConfig
+	ContentSource *util.SourceConfig
+	// ContentDir and GenDir replace the folders of the config when set.
+	ContentDir string
+	GenDir     string
+	// OutputDir is the folder Hugo writes the site to, publicGen/ when
+	// empty.
+	OutputDir string
+	// Languages replaces the languages of the config when set.
+	Languages []string
+	// Clean regenerates GenDir from the theme cache instead of reusing it.
+	Clean bool
+
+	// Fs holds the workshop, the disk when nil. Reading the config at
+	// ConfigPath, fetching sources and running Hugo need the disk: on
+	// another Fs, such as afero.NewMemMapFs() in tests, set Config, put the
+	// content and theme in ContentDir and GenDir and set SkipHugo.
+	Fs afero.Fs
+	// SkipHugo assembles GenDir without building the site.
+	SkipHugo bool
+
+	// Logger prints the messages of the build, including the progress of
+	// fetching the sources, to standard output when nil.
+	Logger Logger
+	// Progress receives the events of the build when set.
+	Progress Progress
+}
+
+// Event kinds reported to Progress.
+const (
+	EventPhase   = "phase"
+	EventPage    = "page"
+	EventAsset   = "asset"
+	EventWarning = "warning"
+)
+
+// Event is a step of a build: a phase starting, a page written, an asset
+// copied or a warning. Skipped is set on the pages and assets left as the
+// previous build generated them.
+type Event struct {
+	Kind     string
+	Phase    string
+	Module   string
+	Language string
+	File     string
+	Source   string
+	Size     int64
+	Message  string
+	Skipped  bool
+}
+
+// Progress is called with the events of a build as it goes.
+type Progress interface {
+	OnEvent(event Event)
+}
+
+// ProgressFunc adapts a function to Progress.
+type ProgressFunc func(event Event)
+
+func (f ProgressFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// workshop is a workshop config with its paths resolved against WorkDir,
+// and the files around it a build reads and writes.
+type workshop struct {
+	config      *util.WorkshopConfig
+	environment string
+	lock        *util.LockFile
+	lockFile    string
+	manifest    string
+	themeCache  string
+	reportFile  string
+	output      string
+	workDir     string
+	clean       bool
+	skipHugo    bool
+	genDir      string
+	fs          afero.Fs
+	log         Logger
+	// out receives what fetching the sources prints, through log.
+	out io.Writer
+	// published is set once publish changed GenDir or the output.
+	published bool
+}
+
+// logWriter prints what is written to it to a Logger, as is.
+type logWriter struct {
+	log Logger
+}
+
+func (writer logWriter) Write(p []byte) (int, error) {
+	writer.log.Printf("%s", p)
+	return len(p), nil
+}
+
+// path resolves file against WorkDir.
+func (w *workshop) path(file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(w.workDir, file)
+}
+
+// source returns source with a local location resolved against WorkDir.
+// Lock entries keep the location as configured.
+func (w *workshop) source(source util.SourceConfig) util.SourceConfig {
+	if local := w.path(source.Location); !filepath.IsAbs(source.Location) && util.IsLocalSource(local) {
+		source.Location = local
+	}
+	return source
+}
+
+// load reads the config and the lock file of the workshop opts describe.
+func load(opts Options) (*workshop, error) {
+	w := &workshop{workDir: opts.WorkDir, environment: opts.Environment, clean: opts.Clean, skipHugo: opts.SkipHugo, fs: opts.Fs, log: opts.Logger}
+	if w.log == nil {
+		w.log = log.New(os.Stdout, "", 0)
+	}
+	if w.fs == nil {
+		w.fs = util.OsFs
+	}
+	w.out = logWriter{w.log}
+	if logger, ok := w.log.(interface{ Writer() io.Writer }); ok {
+		w.out = logger.Writer()
+	}
+
+	config := opts.Config
+	if config == nil {
+		configPath, err := util.FindConfigIn(opts.WorkDir, w.path(opts.ConfigPath))
+		if err != nil {
+			return nil, util.WithExitCode(util.ExitConfig, err)
+		}
+		if config, err = util.DetermineConfig(configPath); err != nil {
+			return nil, util.WithExitCode(util.ExitConfig, err)
+		}
+	}
+	// Work on a copy, the paths are rewritten below.
+	resolved := *config
+	resolved.Branding.CoBrandLogos = append([]string(nil), config.Branding.CoBrandLogos...)
+	w.config = &resolved
+	if err := w.config.UseEnvironment(opts.Environment); err != nil {
+		return nil, util.WithExitCode(util.ExitConfig, err)
+	}
+
+	if opts.ThemeSource != nil {
+		w.config.ThemeSource = *opts.ThemeSource
+	}
+	if opts.ContentSource != nil {
+		w.config.ContentSource = *opts.ContentSource
+	}
+	if opts.ContentDir != "" {
+		w.config.ContentDir = opts.ContentDir
+	}
+	if opts.GenDir != "" {
+		w.config.GenDir = opts.GenDir
+	}
+	if len(opts.Languages) > 0 {
+		w.config.Languages = opts.Languages
+	}
+	w.config.ContentDir = w.path(w.config.ContentDir)
+	w.config.GenDir = w.path(w.config.GenDir)
+	w.config.Branding.Template = w.path(w.config.Branding.Template)
+	w.config.Branding.Logo = w.path(w.config.Branding.Logo)
+	for i, logo := range w.config.Branding.CoBrandLogos {
+		w.config.Branding.CoBrandLogos[i] = w.path(logo)
+	}
+
+	w.lockFile = w.path(util.LockFileName)
+	w.manifest = w.path("manifest.yml")
+	w.themeCache = w.path(util.ThemeCacheDir)
+	w.reportFile = w.path(ReportFileName)
+	output, err := filepath.Abs(w.path(firstNonEmpty(opts.OutputDir, util.DefaultOutputDir)))
+	if err != nil {
+		return nil, err
+	}
+	w.output = output
+	if err := util.CheckOwnFolder(w.config.GenDir, w.workDir); err != nil {
+		return nil, util.WithExitCode(util.ExitConfig, err)
+	}
+	if err := w.checkOutput(); err != nil {
+		return nil, util.WithExitCode(util.ExitConfig, err)
+	}
+
+	if w.lock, err = util.ReadLockFile(w.fs, w.lockFile); err != nil {
+		return nil, util.WithExitCode(util.ExitConfig, err)
+	}
+	return w, nil
+}
+
+// checkOutput refuses an output folder that holds the workshop or the home
+// folder, as a build moves the output folder aside and replaces it, or one
+// inside GenDir.
+func (w *workshop) checkOutput() error {
+	if err := util.CheckOwnFolder(w.output, w.workDir); err != nil {
+		return err
+	}
+	for _, dir := range []string{w.config.GenDir, w.config.ContentDir} {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if util.Within(w.output, dir) {
+			return fmt.Errorf("cannot build into %s, it holds %s", w.output, dir)
+		}
+	}
+	if genDir, err := filepath.Abs(w.config.GenDir); err == nil && util.Within(genDir, w.output) {
+		return fmt.Errorf("cannot build into %s, it is inside %s", w.output, genDir)
+	}
+	return nil
+}
+
+// Build builds the workshop opts describe and writes build-report.json next
+// to its config. The returned Result is the report, also when the build
+// failed after the config was read. Errors carry the exit code of their
+// failure class, see util.ExitCode.
+//
+// GenDir and the site are built in staging folders and swapped into place
+// once the build succeeded; the site it replaces is kept with
+// PreviousSuffix. A build that fails, or is stopped by canceling ctx, leaves
+// both as they were.
+func Build(ctx context.Context, opts Options) (*Result, error) {
+	w, err := load(opts)
+	if err != nil {
+		return nil, err
+	}
+	result := newResult(w.environment, w.log, opts.Progress)
+
+	// Build into the staging folders, leftovers of a build that was killed
+	// are discarded first.
+	w.genDir = w.config.GenDir
+	w.config.GenDir = w.genDir + StagingSuffix
+	result.stage(w.config.GenDir, w.genDir)
+	w.discard()
+
+	err = assembleWorkshop(ctx, w, result)
+	if err == nil {
+		err = runHugo(ctx, w, result)
+	}
+	if err == nil {
+		err = w.publish()
+	}
+	if err != nil {
+		if !w.published {
+			w.log.Printf("Discarding the build, %s and %s are left as they were\n", w.genDir, w.output)
+		}
+		w.discard()
+	} else if result.Manifest != "" {
+		w.log.Printf("Deploy the workshop with `cf push -f %s`\n", result.Manifest)
+	}
+	result.finish(err)
+	if writeErr := result.write(w.fs, w.reportFile); writeErr != nil {
+		w.log.Printf("Warning %s\n", writeErr)
+	}
+	return result, err
+}
+
+// publish swaps the staged GenDir and site into place, keeping the previous
+// site, and records the sources they were built from. Both are moved into
+// place before the old GenDir is dropped, so GenDir is rolled back when the
+// site cannot be swapped.
+func (w *workshop) publish() error {
+	oldGenDir := w.genDir + ".old"
+	if err := swapDir(w.fs, w.config.GenDir, w.genDir, oldGenDir); err != nil {
+		return err
+	}
+	if !w.skipHugo {
+		if err := swapDir(w.fs, w.output+StagingSuffix, w.output, w.output+PreviousSuffix); err != nil {
+			if undoErr := unswapDir(w.fs, w.config.GenDir, w.genDir, oldGenDir); undoErr != nil {
+				w.published = true
+				return fmt.Errorf("%+v, and cannot restore %s + %+v", err, w.genDir, undoErr)
+			}
+			return err
+		}
+	}
+	w.published = true
+	w.config.GenDir = w.genDir
+	if err := w.fs.RemoveAll(oldGenDir); err != nil {
+		w.log.Printf("Warning cannot remove %s + %+v\n", oldGenDir, err)
+	}
+	return w.lock.Write(w.fs, w.lockFile)
+}
+
+// discard removes the staging folders.
+func (w *workshop) discard() {
+	for _, staging := range []string{w.genDir + StagingSuffix, w.output + StagingSuffix} {
+		if err := w.fs.RemoveAll(staging); err != nil {
+			w.log.Printf("Warning cannot remove %s + %+v\n", staging, err)
+		}
+	}
+}
+
+// assembleWorkshop sets up the staged GenDir: the theme, the content and the
+// pages generated from the config.
+func assembleWorkshop(ctx context.Context, w *workshop, result *Result) error {
+	config := w.config
+	result.phase("theme")
+	w.log.Printf("Setting up base theme...\n")
+	if err := setTheme(ctx, w); err != nil {
+		return util.WithExitCode(util.ExitSource, err)
+	}
+	result.Theme = w.lock.Theme
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	result.phase("content")
+	if err := fetchWorkshopContent(ctx, w); err != nil {
+		return util.WithExitCode(util.ExitSource, err)
+	}
+	result.Content = w.lock.Content
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	result.phase("assemble")
+	staged := stagingFs{w.fs}
+	generated, err := newGeneratedFiles(staged, config.GenDir, w.themeCache, w.log)
+	if err != nil {
+		return err
+	}
+	languages := util.WorkshopLanguages(config)
+	result.Languages = languages
+	if err := generated.useCache(config, languages); err != nil {
+		return err
+	}
+	w.log.Printf("Building the workshop in %s...\n", strings.Join(languages, ", "))
+	if err := setSiteConfig(staged, config, languages); err != nil {
+		return err
+	}
+	if err := setSiteLayouts(staged, config); err != nil {
+		return err
+	}
+	if err := setManifestRoute(staged, config, w.manifest, w.genDir, generated, result); err != nil {
+		return err
+	}
+	var errs util.Errors
+	errs.Add(setWorkshopTitle(staged, config, languages, generated, result))
+	errs.Add(setWorkshopContent(ctx, staged, config, languages, generated, result))
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := errs.Err(); err != nil {
+		return util.WithExitCode(util.ExitContent, err)
+	}
+	if result.Skipped.Pages > 0 || result.Skipped.Assets > 0 {
+		w.log.Printf("Skipped %d unchanged pages and %d unchanged assets\n", result.Skipped.Pages, result.Skipped.Assets)
+	}
+	if err := generated.finish(); err != nil {
+		return err
+	}
+	return removeThemeHomepages(staged, config, languages)
+}
+
+// runHugo builds the static site from the staged GenDir into the staged
+// output folder. Hugo cannot be interrupted: when ctx is canceled first, the
+// build returns without waiting for Hugo.
+func runHugo(ctx context.Context, w *workshop, result *Result) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if w.skipHugo {
+		return nil
+	}
+	if !util.IsOsFs(w.fs) {
+		return fmt.Errorf("hugo can only build a workshop on the disk, set SkipHugo")
+	}
+	result.phase("hugo")
+	w.log.Printf("Building Static Website Content in %s ...\n", w.output)
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	done := make(chan commands.Response, 1)
+	go func() {
+		done <- commands.Execute([]string{"-s", w.config.GenDir + "/", "-d", w.output + StagingSuffix})
+	}()
+	// Hugo cannot be interrupted, so a canceled build waits for it to stop
+	// writing to the staging folder before that is discarded.
+	var resp commands.Response
+	select {
+	case resp = <-done:
+	case <-ctx.Done():
+		w.log.Printf("Waiting for Hugo to finish...\n")
+		<-done
+		return ctx.Err()
+	}
+	result.hugo(resp.Result)
+
+	if resp.Err != nil {
+		if resp.IsUserError() {
+			resp.Cmd.Println("")
+			resp.Cmd.Println(resp.Cmd.UsageString())
+		}
+		return util.WithExitCode(util.ExitHugo, fmt.Errorf("hugo failed to build the site + %+v", resp.Err))
+	}
+	return nil
+}
+
+// setTheme stages the existing GenDir, or a copy of the theme when there is
+// none. With clean, the existing GenDir is replaced by a fresh copy of the
+// theme cache.
+func setTheme(ctx context.Context, w *workshop) error {
+	config := w.config
+	if _, err := w.fs.Stat(w.genDir); err == nil {
+		if !w.clean {
+			w.log.Printf("Using existing %s folder.. (run `dscda build --clean` to regenerate it or `dscda update` to refresh it)\n", w.genDir)
+			return stageDir(w.fs, w.genDir, config.GenDir)
+		}
+		w.log.Printf("Regenerating %s from the theme...\n", w.genDir)
+	}
+	if !util.IsOsFs(w.fs) {
+		return fmt.Errorf("cannot fetch the theme, %s does not exist", w.genDir)
+	}
+	commit, err := util.CacheTheme(ctx, w.out, w.themeCache, w.source(config.ThemeSource), w.lock.Theme)
+	if err != nil {
+		return err
+	}
+	w.lock.Theme = util.NewLockedSource(config.ThemeSource, commit)
+	return util.CopyTheme(w.themeCache, config.GenDir)
+}
+
+// fetchWorkshopContent fetches the content at the pinned ref, unless it has
+// been fetched before. An existing checkout is built as it is; one at another
+// commit than dscda.lock pins is refused rather than moved, as it is the
+// author's working copy.
+func fetchWorkshopContent(ctx context.Context, w *workshop) error {
+	config, lock := w.config, w.lock
+	if _, err := w.fs.Stat(config.ContentDir); os.IsNotExist(err) {
+		if !util.IsOsFs(w.fs) {
+			return fmt.Errorf("cannot fetch the content, %s does not exist", config.ContentDir)
+		}
+		commit, err := util.FetchSource(ctx, w.out, w.source(config.ContentSource).Location, config.ContentDir, lock.Content.PinnedRef(config.ContentSource))
+		if err != nil {
+			return err
+		}
+		lock.Content = util.NewLockedSource(config.ContentSource, commit)
+		w.log.Printf("Adjusting workshop content locally can be done within the %s folder. Once your content is ready to be shared with your fellow team members, commit it back to pace workshop content! \n", config.ContentDir)
+	} else if commit, err := util.HeadCommit(config.ContentDir); err == nil {
+		if err := w.checkPinnedContent(commit); err != nil {
+			return err
+		}
+		if !lock.Content.Matches(config.ContentSource) {
+			lock.Content = util.NewLockedSource(config.ContentSource, commit)
+		}
+	}
+	return nil
+}
+
+// checkPinnedContent refuses a content checkout at commit when dscda.lock
+// pins another one.
+func (w *workshop) checkPinnedContent(commit string) error {
+	pinned := w.lock.Content
+	if !pinned.Matches(w.config.ContentSource) || pinned.Commit == "" || pinned.Commit == commit {
+		return nil
+	}
+	return fmt.Errorf("%s is checked out at %s but %s pins %s; check out the pinned commit, run `dscda update` to pin the latest content, or change the ref of contentSource", w.config.ContentDir, commit, util.LockFileName, pinned.Commit)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
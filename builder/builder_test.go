@@ -0,0 +1,401 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildInMemory(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for file, content := range map[string]string{
+		"/work/gen/config.toml":                    "title = \"Theme\"\n",
+		"/work/content/example/example-demo.en.md": "# Example\n",
+		"/work/content/example/diagram.png":        "png",
+	} {
+		if err := afero.WriteFile(fs, file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := util.NewDefaultConfig()
+	config.WorkshopSubject = "Cassandra"
+	config.ContentDir = "content"
+	config.GenDir = "gen"
+	config.Modules = []util.ModuleConfig{{
+		Type:    "demos",
+		Content: []util.ContentConfig{{Name: "example-demo", Filename: "example/example-demo"}},
+	}}
+
+	result, err := Build(context.Background(), Options{
+		WorkDir:  "/work",
+		Config:   config,
+		Fs:       fs,
+		SkipHugo: true,
+		Logger:   log.New(ioutil.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || len(result.Assets) != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	for _, file := range []string{
+		"/work/gen/content/_index.en.md",
+		"/work/gen/content/demos/_index.en.md",
+		"/work/gen/content/demos/example-demo.en.md",
+		"/work/gen/content/demos/example-demo/diagram.png",
+		"/work/gen/" + manifestFile,
+		"/work/" + util.LockFileName,
+		"/work/" + ReportFileName,
+	} {
+		if exists, _ := afero.Exists(fs, file); !exists {
+			t.Errorf("%s was not written", file)
+		}
+	}
+}
+
+func TestBuildRoutesDeployedManifest(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for file, content := range map[string]string{
+		"/work/gen/config.toml":                    "title = \"Theme\"\n",
+		"/work/content/example/example-demo.en.md": "# Example\n",
+		"/work/manifest.yml":                       util.DefaultManifest,
+	} {
+		if err := afero.WriteFile(fs, file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := util.NewDefaultConfig()
+	config.WorkshopSubject = "Cassandra"
+	config.WorkshopHostname = "cassandra.example.com"
+	config.ContentDir = "content"
+	config.GenDir = "gen"
+	config.Modules = []util.ModuleConfig{{
+		Type:    "demos",
+		Content: []util.ContentConfig{{Name: "example-demo", Filename: "example/example-demo"}},
+	}}
+
+	result, err := Build(context.Background(), Options{
+		WorkDir:  "/work",
+		Config:   config,
+		Fs:       fs,
+		SkipHugo: true,
+		Logger:   log.New(ioutil.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Manifest != "/work/gen/manifest.yml" {
+		t.Fatalf("manifest to deploy = %q", result.Manifest)
+	}
+	data, err := afero.ReadFile(fs, result.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Applications []map[string]interface{} `yaml:"applications"`
+	}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for _, app := range manifest.Applications {
+		if _, ok := app["random-route"]; ok {
+			t.Errorf("the deployed manifest keeps random-route:\n%s", data)
+		}
+		routes, _ := app["routes"].([]interface{})
+		if len(routes) != 1 || routes[0].(map[string]interface{})["route"] != "cassandra.example.com" {
+			t.Errorf("the deployed manifest is not routed to workshopHostname:\n%s", data)
+		}
+	}
+	if len(manifest.Applications) == 0 {
+		t.Errorf("the deployed manifest has no applications:\n%s", data)
+	}
+	if workdir, _ := afero.ReadFile(fs, "/work/manifest.yml"); string(workdir) != util.DefaultManifest {
+		t.Errorf("the manifest of the workdir was changed:\n%s", workdir)
+	}
+}
+
+func TestIncrementalBuild(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for file, content := range map[string]string{
+		"/work/gen/config.toml":                    "title = \"Theme\"\n",
+		"/work/content/example/example-demo.en.md": "# Example\n",
+		"/work/content/example/other-demo.en.md":   "# Other\n",
+		"/work/content/example/diagram.png":        "png",
+		"/work/content/example/images/step.png":    "step",
+	} {
+		if err := afero.WriteFile(fs, file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	build := func() *Result {
+		config := util.NewDefaultConfig()
+		config.WorkshopSubject = "Cassandra"
+		config.ContentDir = "content"
+		config.GenDir = "gen"
+		config.Modules = []util.ModuleConfig{{
+			Type: "demos",
+			Content: []util.ContentConfig{
+				{Name: "example-demo", Filename: "example/example-demo"},
+				{Name: "other-demo", Filename: "example/other-demo"},
+			},
+		}}
+		result, err := Build(context.Background(), Options{
+			WorkDir:  "/work",
+			Config:   config,
+			Fs:       fs,
+			SkipHugo: true,
+			Logger:   log.New(ioutil.Discard, "", 0),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := build(); result.Skipped != (SkippedItems{}) {
+		t.Errorf("first build skipped %+v", result.Skipped)
+	}
+	if result := build(); result.Skipped != (SkippedItems{Pages: 2, Assets: 4}) {
+		t.Errorf("unchanged build skipped %+v", result.Skipped)
+	}
+
+	if err := afero.WriteFile(fs, "/work/content/example/example-demo.en.md", []byte("# Changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := afero.WriteFile(fs, "/work/content/example/images/step.png", []byte("new step"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result := build(); result.Skipped != (SkippedItems{Pages: 1, Assets: 2}) {
+		t.Errorf("changed build skipped %+v", result.Skipped)
+	}
+	page, _ := afero.ReadFile(fs, "/work/gen/content/demos/example-demo.en.md")
+	if !strings.Contains(string(page), "# Changed") {
+		t.Errorf("changed page = %q", page)
+	}
+	step, _ := afero.ReadFile(fs, "/work/gen/content/demos/example-demo/images/step.png")
+	if string(step) != "new step" {
+		t.Errorf("changed asset = %q", step)
+	}
+}
+
+func TestDryRunMatchesBuild(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for file, content := range map[string]string{
+		"/work/gen/config.toml":                    "title = \"Theme\"\n",
+		"/work/content/example/example-demo.en.md": "# Example\n",
+		"/work/content/example/example-demo.es.md": "# Ejemplo\n",
+		"/work/content/example/other-demo.en.md":   "# Other\n",
+		"/work/content/example/images/step.png":    "step",
+	} {
+		if err := afero.WriteFile(fs, file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := util.NewDefaultConfig()
+	config.WorkshopSubject = "Cassandra"
+	config.ContentDir = "content"
+	config.GenDir = "gen"
+	config.Languages = []string{"en", "es"}
+	config.Modules = []util.ModuleConfig{{
+		Type: "demos",
+		Content: []util.ContentConfig{
+			{Name: "example-demo", Filename: "example/example-demo"},
+			{Name: "other-demo", Filename: "example/other-demo"},
+		},
+	}}
+	opts := Options{WorkDir: "/work", Config: config, Fs: fs, SkipHugo: true, Logger: log.New(ioutil.Discard, "", 0)}
+	files := func() []string {
+		var files []string
+		_ = afero.Walk(fs, "/", func(file string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				files = append(files, file)
+			}
+			return err
+		})
+		return files
+	}
+
+	before := files()
+	plan, err := DryRun(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := files(); strings.Join(after, "\n") != strings.Join(before, "\n") {
+		t.Errorf("dry run changed the files to %v", after)
+	}
+	if len(plan.Errors) > 0 || len(plan.MissingTranslations["es"]) != 1 {
+		t.Errorf("plan = %+v", plan)
+	}
+
+	if _, err := Build(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	var planned []string
+	for _, page := range plan.Pages {
+		planned = append(planned, strings.TrimPrefix(page.File, "/work/gen/"))
+	}
+	for _, asset := range plan.Assets {
+		planned = append(planned, strings.TrimPrefix(asset.Destination, "/work/gen/"))
+	}
+	sort.Strings(planned)
+	data, err := afero.ReadFile(fs, "/work/gen/"+manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var built manifest
+	if err := json.Unmarshal(data, &built); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(planned, "\n") != strings.Join(built.Files, "\n") {
+		t.Errorf("planned %v, built %v", planned, built.Files)
+	}
+
+	config.Modules[0].Content = config.Modules[0].Content[:1]
+	plan, err = DryRun(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(plan.Removed, " ") != "/work/gen/content/demos/other-demo.en.md /work/gen/content/demos/other-demo/images/step.png" {
+		t.Errorf("removed = %v", plan.Removed)
+	}
+}
+
+func TestDryRunDoesNotFetchContent(t *testing.T) {
+	work := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(work, "gen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := util.NewDefaultConfig()
+	config.ContentDir = "content"
+	config.GenDir = "gen"
+	config.ContentSource = util.SourceConfig{Location: "https://example.com/workshop-content"}
+	config.Modules = []util.ModuleConfig{{
+		Type:    "demos",
+		Content: []util.ContentConfig{{Name: "example-demo", Filename: "example/example-demo"}},
+	}}
+	pinned := "0123456789abcdef0123456789abcdef01234567"
+	lock := &util.LockFile{Content: util.NewLockedSource(config.ContentSource, pinned)}
+	if err := lock.Write(util.OsFs, filepath.Join(work, util.LockFileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := DryRun(context.Background(), Options{WorkDir: work, Config: config, Logger: log.New(ioutil.Discard, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Content.Commit != pinned || plan.Unverified == "" || len(plan.Pages) > 0 {
+		t.Errorf("plan = %+v", plan)
+	}
+	if _, err := os.Stat(filepath.Join(work, "content")); !os.IsNotExist(err) {
+		t.Errorf("the dry run fetched the content + %+v", err)
+	}
+}
+
+func TestBuildLeavesUnpinnedContentAlone(t *testing.T) {
+	work := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(work, "gen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	contentDir := filepath.Join(work, "content")
+	repo, err := git.PlainInit(contentDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commits []string
+	for _, text := range []string{"# Example\n", "# Example, edited locally\n"} {
+		if err := os.MkdirAll(filepath.Join(contentDir, "example"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(contentDir, "example", "example-demo.en.md"), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("example/example-demo.en.md"); err != nil {
+			t.Fatal(err)
+		}
+		signature := &object.Signature{Name: "dscda", Email: "dscda@example.com", When: time.Now()}
+		hash, err := worktree.Commit("edit", &git.CommitOptions{Author: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, hash.String())
+	}
+	draft := filepath.Join(contentDir, "example", "draft.en.md")
+	if err := ioutil.WriteFile(draft, []byte("# Draft\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := util.NewDefaultConfig()
+	config.WorkshopSubject = "Cassandra"
+	config.ContentDir = "content"
+	config.GenDir = "gen"
+	config.ContentSource = util.SourceConfig{Location: "https://example.com/workshop-content"}
+	config.Modules = []util.ModuleConfig{{
+		Type:    "demos",
+		Content: []util.ContentConfig{{Name: "example-demo", Filename: "example/example-demo"}},
+	}}
+	lock := &util.LockFile{Content: util.NewLockedSource(config.ContentSource, commits[0])}
+	if err := lock.Write(util.OsFs, filepath.Join(work, util.LockFileName)); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{WorkDir: work, Config: config, SkipHugo: true, Logger: log.New(ioutil.Discard, "", 0)}
+
+	if _, err := Build(context.Background(), opts); util.ExitCode(err) != util.ExitSource {
+		t.Errorf("building content at another commit than the lock pins = %v", err)
+	}
+	if head, _ := util.HeadCommit(contentDir); head != commits[1] {
+		t.Errorf("the build moved the content to %s", head)
+	}
+	if _, err := os.Stat(draft); err != nil {
+		t.Errorf("the build removed an untracked file + %+v", err)
+	}
+	plan, err := DryRun(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Errors) == 0 {
+		t.Errorf("the plan does not report the unpinned content")
+	}
+}
+
+func TestBuildRejectsOutputHoldingWorkshop(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	config := util.NewDefaultConfig()
+	config.ContentDir = "content"
+	config.GenDir = "gen"
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, output := range []string{".", "..", "/", home, "gen", "content", "gen/public"} {
+		_, err := Build(context.Background(), Options{
+			WorkDir:   "/work",
+			Config:    config,
+			OutputDir: output,
+			Fs:        fs,
+			SkipHugo:  true,
+			Logger:    log.New(ioutil.Discard, "", 0),
+		})
+		if util.ExitCode(err) != util.ExitConfig {
+			t.Errorf("building into %s = %v", output, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// buildCacheFile records what the pages and assets of the last build were
+// generated from, so unchanged ones are not written again.
+const buildCacheFile = ".dscda/build-cache.json"
+
+// buildCacheVersion is part of the config hash. Bump it when the pages
+// generated from the same content change, so older caches are ignored.
+const buildCacheVersion = "1"
+
+type buildCache struct {
+	// Config is the hash of the workshop config; pages are only reused
+	// while it is unchanged.
+	Config string `json:"config"`
+	// Pages maps the pages, relative to GenDir, to the hash of what they
+	// were generated from.
+	Pages map[string]string `json:"pages"`
+	// Assets maps the copied assets, relative to GenDir, to the hash of their
+	// source.
+	Assets map[string]cachedAsset `json:"assets"`
+}
+
+// cachedAsset is the hash of an asset. Its size and modification time save
+// hashing it again while they are unchanged.
+type cachedAsset struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+func newBuildCache(config string) *buildCache {
+	return &buildCache{Config: config, Pages: map[string]string{}, Assets: map[string]cachedAsset{}}
+}
+
+// useCache loads the cache of the previous build. Its pages are dropped when
+// the config changed since, its assets are kept.
+func (generated *generatedFiles) useCache(config interface{}, languages []string) error {
+	data, err := json.Marshal(struct {
+		Version   string      `json:"version"`
+		Config    interface{} `json:"config"`
+		Languages []string    `json:"languages"`
+	}{buildCacheVersion, config, languages})
+	if err != nil {
+		return err
+	}
+	generated.cache = newBuildCache(hash(data))
+	generated.cached = newBuildCache("")
+
+	data, err = afero.ReadFile(generated.fs, filepath.Join(generated.genDir, buildCacheFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read %s + %+v", buildCacheFile, err)
+	}
+	if err := json.Unmarshal(data, generated.cached); err != nil {
+		generated.log.Printf("Warning ignoring %s + %+v\n", buildCacheFile, err)
+		generated.cached = newBuildCache("")
+		return nil
+	}
+	if generated.cached.Config != generated.cache.Config {
+		generated.cached.Pages = map[string]string{}
+	}
+	return nil
+}
+
+// unchangedPage records what the page file is generated from and reports
+// whether the previous build generated it from the same inputs.
+func (generated *generatedFiles) unchangedPage(file string, inputs ...string) bool {
+	if generated.cache == nil {
+		return false
+	}
+	digest := sha256.New()
+	for _, input := range inputs {
+		fmt.Fprintf(digest, "%d:%s", len(input), input)
+	}
+	relative := generated.relative(file)
+	generated.cache.Pages[relative] = hex.EncodeToString(digest.Sum(nil))
+	return generated.cached.Pages[relative] == generated.cache.Pages[relative] && generated.exists(file)
+}
+
+// unchangedAsset records the hash of the asset source copied to destination
+// and reports whether the previous build copied the same content there.
+func (generated *generatedFiles) unchangedAsset(source string, info os.FileInfo, destination string) (bool, error) {
+	if generated.cache == nil {
+		return false, nil
+	}
+	relative := generated.relative(destination)
+	previous, ok := generated.cached.Assets[relative]
+	current := cachedAsset{Hash: previous.Hash, Size: info.Size(), ModTime: info.ModTime()}
+	if !ok || previous.Size != current.Size || !previous.ModTime.Equal(current.ModTime) {
+		file, err := generated.fs.Open(source)
+		if err != nil {
+			return false, err
+		}
+		defer file.Close()
+		digest := sha256.New()
+		if _, err := io.Copy(digest, file); err != nil {
+			return false, fmt.Errorf("cannot read %s + %+v", source, err)
+		}
+		current.Hash = hex.EncodeToString(digest.Sum(nil))
+	}
+	generated.cache.Assets[relative] = current
+	return ok && previous.Hash == current.Hash && generated.exists(destination), nil
+}
+
+func (generated *generatedFiles) exists(file string) bool {
+	_, err := generated.fs.Stat(file)
+	return err == nil
+}
+
+// writeCache records the hashes of this build for the next one.
+func (generated *generatedFiles) writeCache() error {
+	if generated.cache == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(generated.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(generated.genDir, buildCacheFile)
+	if err := afero.WriteFile(generated.fs, path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", path, err)
+	}
+	return nil
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
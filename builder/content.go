@@ -0,0 +1,337 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
+)
+
+// Workshop Content
+func setWorkshopContent(ctx context.Context, fs afero.Fs, config *util.WorkshopConfig, languages []string, generated *generatedFiles, result *Result) error {
+	var errs util.Errors
+	translations := newTranslationReport(config.TranslationFallback)
+	for _, module := range config.Modules {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := setModuleIndex(fs, config, module.Type, languages, generated, result); err != nil {
+			errs.Add(err)
+			continue
+		}
+		errs.Add(setWorkshopFolder(ctx, fs, config, module.Content, module.Type, languages, translations, generated, result))
+	}
+	result.translations(translations)
+	errs.Add(translations.finish(languages[0], result.log))
+	return errs.Err()
+}
+
+// setModuleIndex writes the chapter page of a module type for every language.
+// The theme's own chapter pages of the built in types are kept unless
+// moduleTypes redeclares them.
+func setModuleIndex(fs afero.Fs, config *util.WorkshopConfig, name string, languages []string, generated *generatedFiles, result *Result) error {
+	moduleType, declared, ok := config.ModuleType(name)
+	if !ok {
+		return fmt.Errorf("%s content is not of a declared module type, known types are %s", name, strings.Join(config.ModuleTypeNames(), ", "))
+	}
+	folder := config.GenDir + "/content/" + name
+	if err := fs.MkdirAll(folder, os.FileMode(0777)); err != nil {
+		return fmt.Errorf("cannot create module folder, %s, %+v", folder, err)
+	}
+	for _, language := range languages {
+		indexFile := folder + "/_index." + language + ".md"
+		if !declared {
+			kept, err := generated.keepTheme(indexFile)
+			if err != nil {
+				return err
+			}
+			if kept {
+				continue
+			}
+		}
+		if err := createChapter(fs, indexFile, moduleType); err != nil {
+			return err
+		}
+		generated.add(indexFile)
+		result.page(name, language, indexFile)
+		result.plannedPage(PlannedPage{File: indexFile, Language: language, Title: moduleType.Title, Weight: moduleType.Weight, Source: "module type " + name})
+	}
+	return nil
+}
+
+func setWorkshopFolder(ctx context.Context, fs afero.Fs, config *util.WorkshopConfig, contents []util.ContentConfig, name string, languages []string, translations *translationReport, generated *generatedFiles, result *Result) error {
+	var errs util.Errors
+	for order, content := range contents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := setWorkshopExtras(fs, config, content, name, generated, result)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		for _, language := range languages {
+			fileName := strings.Split(content.Filename, "/")
+			pageFile := config.GenDir + "/content/" + name + "/" + fileName[len(fileName)-1] + "." + language + ".md"
+			contentPath := config.ContentDir + "/" + content.Filename
+			markdown := contentPath + "." + language + ".md"
+			notice := ""
+			if _, err := fs.Stat(markdown); os.IsNotExist(err) && language != languages[0] {
+				translations.add(language, name+"/"+fileName[len(fileName)-1])
+				if config.TranslationFallback == util.FallbackDefault {
+					markdown = contentPath + "." + languages[0] + ".md"
+					notice = fmt.Sprintf("<div class=\"notices info\"><p>%s</p></div>\n\n", util.UntranslatedNotice(language, languages[0]))
+				}
+			}
+			page := PlannedPage{File: pageFile, Language: language, Title: content.Name, Weight: order + 3, Source: markdown, Fallback: notice != ""}
+			if source, err := afero.ReadFile(fs, markdown); err == nil && generated.unchangedPage(pageFile, content.Name, strconv.Itoa(order), notice, string(source)) {
+				generated.add(pageFile)
+				result.skippedPage(name, language, pageFile)
+				result.plannedPage(page)
+				continue
+			}
+
+			err := createPage(fs, pageFile, content.Name, order)
+			if err != nil {
+				errs.Add(err)
+				continue
+			}
+			if notice != "" {
+				if err := appendToFile(fs, pageFile, notice); err != nil {
+					errs.Add(err)
+					continue
+				}
+			}
+			err = addMarkdown(fs, pageFile, markdown)
+			if err != nil {
+				errs.Add(fmt.Errorf("cannot add specified demo markdown to file, %s, %+v", fileName[len(fileName)-1]+"."+language+".md", err))
+				continue
+			}
+			if _, err := fs.Stat(pageFile); err == nil {
+				generated.add(pageFile)
+				result.page(name, language, pageFile)
+				result.plannedPage(page)
+			} else if language == languages[0] {
+				result.missingFile(markdown)
+				result.warn("%s not found", markdown)
+			}
+		}
+	}
+	return errs.Err()
+}
+
+func setWorkshopExtras(fs afero.Fs, config *util.WorkshopConfig, curContent util.ContentConfig, contType string, generated *generatedFiles, result *Result) error {
+
+	var (
+		destination string
+		source      string
+	)
+
+	contentPath := strings.Split(curContent.Filename, "/")
+	folders := contentPath[:len(contentPath)-1]
+	folderPath := strings.Join(folders, "/")
+
+	source = config.ContentDir + "/" + folderPath + "/"
+
+	if _, _, ok := config.ModuleType(contType); !ok {
+		return fmt.Errorf("%s content is not of a declared module type, known types are %s", contType, strings.Join(config.ModuleTypeNames(), ", "))
+	}
+	destination = config.GenDir + "/content/" + contType + "/" + contentPath[len(contentPath)-1] + "/"
+	_ = fs.MkdirAll(destination, os.FileMode(0777))
+
+	fds, err := afero.ReadDir(fs, source)
+	if err != nil {
+		result.missingFile(source)
+		return fmt.Errorf("cannot read content folder %s + %+v", source, err)
+	}
+
+	for _, fd := range fds {
+		srcfp := path.Join(source, fd.Name())
+		dstfp := path.Join(destination, fd.Name())
+
+		if !fd.IsDir() {
+			if filepath.Ext(strings.TrimSpace(fd.Name())) != ".md" {
+				unchanged, err := generated.unchangedAsset(srcfp, fd, dstfp)
+				if err != nil {
+					return err
+				}
+				if unchanged {
+					generated.add(dstfp)
+					result.skippedAsset(srcfp, dstfp, fd.Size())
+					continue
+				}
+
+				srcfd, err := fs.Open(srcfp)
+				if err != nil {
+					return err
+				}
+				defer srcfd.Close()
+
+				dstfd, err := fs.Create(dstfp)
+				if err != nil {
+					return err
+				}
+				defer dstfd.Close()
+
+				if _, err = io.Copy(dstfd, srcfd); err != nil {
+					return err
+				}
+				generated.add(dstfp)
+				result.asset(srcfp, dstfp, fd.Size())
+			}
+		} else if err := copyAssets(fs, srcfp, dstfp, generated, result); err != nil {
+			return fmt.Errorf("cannot copy %s + %+v", srcfp, err)
+		}
+	}
+
+	return nil
+}
+
+// copyAssets copies an asset folder of the content, recording every file it
+// writes. Files the previous build copied unchanged are left as they are.
+func copyAssets(fs afero.Fs, source string, destination string, generated *generatedFiles, result *Result) error {
+	return afero.Walk(fs, source, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, src)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(destination, relative)
+		if info.IsDir() {
+			return fs.MkdirAll(dest, info.Mode().Perm()|0700)
+		}
+		unchanged, err := generated.unchangedAsset(src, info, dest)
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			generated.add(dest)
+			result.skippedAsset(src, dest, info.Size())
+			return nil
+		}
+		if err := util.CopyFile(fs, src, dest); err != nil {
+			return err
+		}
+		generated.add(dest)
+		result.asset(src, dest, info.Size())
+		return nil
+	})
+}
+
+// addMarkdown appends additionalMarkDown to the page existingFile, removing
+// the page when additionalMarkDown does not exist.
+func addMarkdown(fs afero.Fs, existingFile string, additionalMarkDown string) error {
+	additionalMarkDownWriter, err := fs.Open(additionalMarkDown)
+	if err != nil {
+		fs.Remove(existingFile)
+		return nil
+	}
+	defer additionalMarkDownWriter.Close()
+	existingFileWriter, err := fs.OpenFile(existingFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file for writing %s", err)
+	}
+	defer existingFileWriter.Close()
+	_, err = io.Copy(existingFileWriter, additionalMarkDownWriter)
+	if err != nil {
+		return fmt.Errorf("failed to append files %s", err)
+	}
+
+	return nil
+}
+
+func createPage(fs afero.Fs, file string, title string, order int) error {
+	f, err := fs.Create(file)
+	if err != nil {
+		return fmt.Errorf("cannot create file, %s, %+v", file, err)
+	}
+	defer f.Close()
+	order = order + 3
+	//header := fmt.Sprintf("+++\ntitle = \"\"\nmenuTitle = \"%s\"\nchapter = false\nweight = %d\ndescription = \"\"\ndraft = false\n+++\n", title, order)
+	header := fmt.Sprintf("+++\ntitle = \"%s\"\nweight = %d\ndescription = \"\"\ndraft = false\n+++\n", title, order)
+	_, err = f.WriteString(header)
+	if err != nil {
+		return fmt.Errorf("cannot write string %s, %+v", header, err)
+	}
+	return nil
+}
+
+func createChapter(fs afero.Fs, file string, moduleType util.ModuleTypeConfig) error {
+	f, err := fs.Create(file)
+	if err != nil {
+		return fmt.Errorf("cannot create file, %s, %+v", file, err)
+	}
+	defer f.Close()
+	header := fmt.Sprintf("+++\ntitle = %q\nweight = %d\nchapter = true\n", moduleType.Title, moduleType.Weight)
+	if moduleType.Icon != "" {
+		header += fmt.Sprintf("pre = %q\n", "<i class='"+moduleType.Icon+"'></i> ")
+	}
+	header += fmt.Sprintf("+++\n\n# %s\n", moduleType.Title)
+	if _, err = f.WriteString(header); err != nil {
+		return fmt.Errorf("cannot write string %s, %+v", header, err)
+	}
+	return nil
+}
+
+func setWorkshopTitle(fs afero.Fs, config *util.WorkshopConfig, languages []string, generated *generatedFiles, result *Result) error {
+	var errs util.Errors
+	for _, language := range languages {
+		localization, err := config.LocalizeIn(fs, language)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		workshopToml := fmt.Sprintf("+++\ntitle = %q\nchapter = true\nweight = 1\n+++\n\n", localization.Title)
+		workshopHomepageContent := workshopToml
+		source := "branding template"
+		if localization.Homepage != "" {
+			source = config.ContentDir + "/" + localization.Homepage
+			homepageContent, err := afero.ReadFile(fs, source)
+			if err != nil {
+				result.missingFile(source)
+				errs.Add(fmt.Errorf("cannot read the homepage %s + %+v", localization.Homepage, err))
+				continue
+			}
+			workshopHomepageContent = workshopHomepageContent + string(homepageContent)
+		} else {
+			homepage, err := renderHomepage(fs, config, localization)
+			if err != nil {
+				errs.Add(err)
+				continue
+			}
+			workshopHomepageContent = workshopHomepageContent + homepage
+		}
+
+		homepageFile := config.GenDir + "/content/_index." + language + ".md"
+		if err := afero.WriteFile(fs, homepageFile, []byte(workshopHomepageContent), 0644); err != nil {
+			errs.Add(fmt.Errorf("cannot write %s + %+v", homepageFile, err))
+			continue
+		}
+		generated.add(homepageFile)
+		result.plannedPage(PlannedPage{File: homepageFile, Language: language, Title: localization.Title, Weight: 1, Source: source})
+	}
+	return errs.Err()
+}
+
+func appendToFile(fs afero.Fs, file string, content string) error {
+	workshop, err := fs.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open nav workshop file")
+	}
+	defer workshop.Close()
+
+	if _, err = workshop.WriteString(content); err != nil {
+		return fmt.Errorf("cannot write to workshop file")
+	}
+	return nil
+}
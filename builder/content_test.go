@@ -0,0 +1,195 @@
+package builder
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// newTestWorkshop returns an in-memory filesystem holding the content files,
+// and a config with its folders on it.
+func newTestWorkshop(t *testing.T, files map[string]string) (afero.Fs, *util.WorkshopConfig) {
+	fs := afero.NewMemMapFs()
+	for file, content := range files {
+		if err := afero.WriteFile(fs, file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := util.NewDefaultConfig()
+	config.ContentDir = "/content"
+	config.GenDir = "/gen"
+	if err := fs.MkdirAll(config.GenDir+"/content", 0755); err != nil {
+		t.Fatal(err)
+	}
+	return fs, config
+}
+
+func newTestGenerated(t *testing.T, fs afero.Fs, config *util.WorkshopConfig) (*generatedFiles, *Result) {
+	logger := log.New(ioutil.Discard, "", 0)
+	generated, err := newGeneratedFiles(fs, config.GenDir, "/cache", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return generated, newResult("", logger, nil)
+}
+
+// dump lists every file under dir on fs with its content.
+func dump(t *testing.T, fs afero.Fs, dir string) string {
+	var files []string
+	err := afero.Walk(fs, dir, func(file string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, file)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	var out strings.Builder
+	for _, file := range files {
+		data, err := afero.ReadFile(fs, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.WriteString("== " + filepath.ToSlash(file) + " ==\n")
+		out.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// golden compares got with testdata/name.golden, rewriting it with -update.
+func golden(t *testing.T, name string, got string) {
+	t.Helper()
+	file := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("%s, run go test -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from %s:\n%s", name, file, got)
+	}
+}
+
+func TestCreatePage(t *testing.T) {
+	fs, config := newTestWorkshop(t, nil)
+	page := config.GenDir + "/content/demos/example-demo.en.md"
+	if err := fs.MkdirAll(filepath.Dir(page), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createPage(fs, page, "Example Demo", 2); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "createPage", dump(t, fs, config.GenDir))
+}
+
+func TestAddMarkdown(t *testing.T) {
+	fs, config := newTestWorkshop(t, map[string]string{
+		"/content/example/example-demo.en.md": "# Example\n\nRun the demo.\n",
+	})
+	page := config.GenDir + "/content/demos/example-demo.en.md"
+	if err := afero.WriteFile(fs, page, []byte("+++\ntitle = \"Example\"\n+++\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMarkdown(fs, page, "/content/example/example-demo.en.md"); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "addMarkdown", dump(t, fs, config.GenDir))
+}
+
+func TestAddMarkdownMissingSource(t *testing.T) {
+	fs, config := newTestWorkshop(t, nil)
+	page := config.GenDir + "/content/demos/example-demo.es.md"
+	if err := afero.WriteFile(fs, page, []byte("+++\n+++\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMarkdown(fs, page, "/content/example/example-demo.es.md"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := afero.Exists(fs, page); exists {
+		t.Errorf("%s was kept without its markdown", page)
+	}
+}
+
+func TestSetWorkshopExtras(t *testing.T) {
+	fs, config := newTestWorkshop(t, map[string]string{
+		"/content/example/example-demo.en.md":      "# Example\n",
+		"/content/example/diagram.png":             "png",
+		"/content/example/images/screen.svg":       "<svg/>",
+		"/content/example/images/nested/notes.txt": "notes",
+	})
+	generated, result := newTestGenerated(t, fs, config)
+	content := util.ContentConfig{Name: "example-demo", Filename: "example/example-demo"}
+	if err := setWorkshopExtras(fs, config, content, "demos", generated, result); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "setWorkshopExtras", dump(t, fs, config.GenDir))
+
+	if len(result.Assets) != 3 {
+		t.Errorf("assets = %+v, want 3", result.Assets)
+	}
+	if !generated.files["content/demos/example-demo/images/nested/notes.txt"] {
+		t.Errorf("generated = %v, nested asset missing", generated.files)
+	}
+}
+
+func TestSetWorkshopExtrasUndeclaredType(t *testing.T) {
+	fs, config := newTestWorkshop(t, map[string]string{"/content/example/example-demo.en.md": ""})
+	generated, result := newTestGenerated(t, fs, config)
+	content := util.ContentConfig{Name: "example-demo", Filename: "example/example-demo"}
+	if err := setWorkshopExtras(fs, config, content, "workshops", generated, result); err == nil {
+		t.Error("expected an error for an undeclared module type")
+	}
+}
+
+func TestSetWorkshopTitle(t *testing.T) {
+	fs, config := newTestWorkshop(t, map[string]string{
+		"/content/home.en.md": "Welcome!\n",
+		"/content/home.es.md": "¡Bienvenidos!\n",
+	})
+	config.WorkshopSubject = "Cassandra"
+	config.WorkshopHomepage = "home.en.md"
+	generated, result := newTestGenerated(t, fs, config)
+	if err := setWorkshopTitle(fs, config, []string{"en", "es", "fr"}, generated, result); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "setWorkshopTitle", dump(t, fs, config.GenDir))
+}
+
+func TestSetWorkshopTitleBranding(t *testing.T) {
+	fs, config := newTestWorkshop(t, map[string]string{
+		"/branding/homepage.html": "<h1>{{ .Title }}</h1>\n<img src=\"{{ .Logo }}\">\n<p style=\"color: {{ .PrimaryColor }}\">{{ .Tagline }}</p>\n",
+		"/branding/logo.svg":      "<svg/>",
+	})
+	config.WorkshopSubject = "Cassandra"
+	config.Branding.Template = "/branding/homepage.html"
+	config.Branding.Logo = "/branding/logo.svg"
+	config.Branding.Tagline = "Learn by doing"
+	generated, result := newTestGenerated(t, fs, config)
+	if err := setWorkshopTitle(fs, config, []string{"en"}, generated, result); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "setWorkshopTitleBranding", dump(t, fs, config.GenDir))
+}
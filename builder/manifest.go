@@ -0,0 +1,161 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
+)
+
+// manifestFile lists the files the last build generated into GenDir,
+// relative to GenDir.
+const manifestFile = ".dscda/manifest.json"
+
+type manifest struct {
+	Files []string `json:"files"`
+}
+
+// generatedFiles tracks the pages and assets a build writes into GenDir.
+// Whatever the previous build generated and this one does not is removed, so
+// rebuilding an existing GenDir yields the same tree as building a fresh one.
+type generatedFiles struct {
+	fs         afero.Fs
+	genDir     string
+	themeCache string
+	previous   map[string]bool
+	files      map[string]bool
+	log        Logger
+	// cache holds the hashes of this build and cached those of the previous
+	// one, once useCache is called.
+	cache  *buildCache
+	cached *buildCache
+}
+
+func newGeneratedFiles(fs afero.Fs, genDir string, themeCache string, log Logger) (*generatedFiles, error) {
+	generated := &generatedFiles{
+		fs:         fs,
+		genDir:     filepath.Clean(genDir),
+		themeCache: themeCache,
+		previous:   map[string]bool{},
+		files:      map[string]bool{},
+		log:        log,
+	}
+	data, err := afero.ReadFile(fs, filepath.Join(genDir, manifestFile))
+	if os.IsNotExist(err) {
+		return generated, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s + %+v", manifestFile, err)
+	}
+	var previous manifest
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("cannot parse %s, run `dscda build --clean` + %+v", manifestFile, err)
+	}
+	for _, file := range previous.Files {
+		generated.previous[file] = true
+	}
+	return generated, nil
+}
+
+// relative returns file relative to GenDir with forward slashes.
+func (generated *generatedFiles) relative(file string) string {
+	relative, err := filepath.Rel(generated.genDir, file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(relative)
+}
+
+func (generated *generatedFiles) add(file string) {
+	generated.files[generated.relative(file)] = true
+}
+
+// keepTheme reports whether file belongs to the theme and should be left
+// alone. A theme file an earlier build overwrote is restored from the theme
+// cache first.
+func (generated *generatedFiles) keepTheme(file string) (bool, error) {
+	relative := generated.relative(file)
+	if !generated.previous[relative] {
+		_, err := generated.fs.Stat(file)
+		return err == nil, nil
+	}
+	cached := filepath.Join(generated.themeCache, filepath.FromSlash(relative))
+	if _, err := generated.fs.Stat(cached); err != nil {
+		return false, nil
+	}
+	if err := util.CopyFile(generated.fs, cached, file); err != nil {
+		return false, fmt.Errorf("cannot restore %s from the theme cache + %+v", file, err)
+	}
+	return true, nil
+}
+
+// finish removes the files the previous build generated and this one did
+// not, along with the folders that leaves empty, and records the files and
+// hashes of this build. Theme files an earlier build overwrote are restored
+// from the theme cache instead.
+func (generated *generatedFiles) finish() error {
+	var orphans []string
+	for file := range generated.previous {
+		if !generated.files[file] {
+			orphans = append(orphans, file)
+		}
+	}
+	sort.Strings(orphans)
+	for _, file := range orphans {
+		path := filepath.Join(generated.genDir, filepath.FromSlash(file))
+		cached := filepath.Join(generated.themeCache, filepath.FromSlash(file))
+		if _, err := generated.fs.Stat(cached); err == nil {
+			if err := util.CopyFile(generated.fs, cached, path); err != nil {
+				return fmt.Errorf("cannot restore %s from the theme cache + %+v", path, err)
+			}
+			continue
+		}
+		if err := generated.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove %s + %+v", path, err)
+		}
+		for dir := filepath.Dir(path); dir != generated.genDir && dir != "."; dir = filepath.Dir(dir) {
+			if removeEmptyDir(generated.fs, dir) != nil {
+				break
+			}
+		}
+	}
+	if len(orphans) > 0 {
+		generated.log.Printf("Removed %d files no longer part of the workshop\n", len(orphans))
+	}
+
+	current := manifest{Files: []string{}}
+	for file := range generated.files {
+		current.Files = append(current.Files, file)
+	}
+	sort.Strings(current.Files)
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(generated.genDir, manifestFile)
+	if err := generated.fs.MkdirAll(filepath.Dir(path), os.FileMode(0755)); err != nil {
+		return fmt.Errorf("cannot create %s + %+v", filepath.Dir(path), err)
+	}
+	if err := afero.WriteFile(generated.fs, path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", path, err)
+	}
+	return generated.writeCache()
+}
+
+// removeEmptyDir removes the folder dir if it is empty. Unlike os.Remove,
+// afero's in-memory Remove also removes folders that are not.
+func removeEmptyDir(fs afero.Fs, dir string) error {
+	empty, err := afero.IsEmpty(fs, dir)
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return fmt.Errorf("%s is not empty", dir)
+	}
+	return fs.Remove(dir)
+}
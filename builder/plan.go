@@ -0,0 +1,338 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
+)
+
+type PlannedSource struct {
+	Location string `json:"location"`
+	Ref      string `json:"ref,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Action   string `json:"action"`
+}
+
+type PlannedPage struct {
+	File     string `json:"file"`
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Weight   int    `json:"weight"`
+	Source   string `json:"source"`
+	Fallback bool   `json:"fallback,omitempty"`
+}
+
+type PlannedAsset struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// Plan describes what a build would do, without doing it.
+type Plan struct {
+	Theme               PlannedSource       `json:"theme"`
+	Content             PlannedSource       `json:"content"`
+	Languages           []string            `json:"languages"`
+	TranslationFallback string              `json:"translationFallback"`
+	Output              string              `json:"output"`
+	Pages               []PlannedPage       `json:"pages"`
+	Assets              []PlannedAsset      `json:"assets"`
+	MissingFiles        []string            `json:"missingFiles"`
+	MissingTranslations map[string][]string `json:"missingTranslations"`
+	Removed             []string            `json:"removed"`
+	Errors              []string            `json:"errors,omitempty"`
+	// Unverified says why the pages and assets are not planned from the
+	// content a build would use, when they are not.
+	Unverified string `json:"unverified,omitempty"`
+}
+
+// DryRun resolves the sources and runs the assembly of Build on a layer
+// that records what it would write, collecting the pages and assets.
+// Nothing is written or fetched: the pages of content that has not been
+// fetched yet are left out, see Plan.Unverified.
+func DryRun(ctx context.Context, opts Options) (*Plan, error) {
+	w, err := load(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	plan, err := planBuild(ctx, w)
+	if err != nil {
+		return nil, util.WithExitCode(util.ExitSource, err)
+	}
+	return plan, nil
+}
+
+func planBuild(ctx context.Context, w *workshop) (*Plan, error) {
+	config := w.config
+	plan := &Plan{
+		TranslationFallback: config.TranslationFallback,
+		Output:              w.output,
+		Pages:               []PlannedPage{},
+		Assets:              []PlannedAsset{},
+		MissingFiles:        []string{},
+		MissingTranslations: map[string][]string{},
+		Removed:             []string{},
+	}
+
+	genExists := false
+	if _, err := w.fs.Stat(config.GenDir); err == nil && !w.clean {
+		genExists = true
+	}
+	theme, err := planTheme(ctx, w, genExists)
+	if err != nil {
+		return nil, err
+	}
+	plan.Theme = theme
+
+	contentDir, err := planContent(ctx, w, plan)
+	if err != nil {
+		return nil, err
+	}
+	if contentDir == "" {
+		plan.Languages = util.WorkshopLanguages(config)
+		return plan, nil
+	}
+
+	// Read the content from wherever it was found. A GenDir that is not
+	// reused starts out as the theme, in a folder of its own when --clean
+	// leaves the existing one behind.
+	fs := newDryRunFs(w.fs)
+	discard := log.New(ioutil.Discard, "", 0)
+	result := newResult(w.environment, discard, nil)
+	result.plan = plan
+	planConfig := *config
+	planConfig.ContentDir = contentDir
+	if !genExists {
+		if _, err := w.fs.Stat(config.GenDir); err == nil {
+			planConfig.GenDir = config.GenDir + StagingSuffix
+			result.stage(planConfig.GenDir, config.GenDir)
+		}
+		if err := seedTheme(fs, w.themeCache, planConfig.GenDir); err != nil {
+			return nil, err
+		}
+	}
+	generated, err := newGeneratedFiles(fs, planConfig.GenDir, w.themeCache, discard)
+	if err != nil {
+		return nil, err
+	}
+
+	languages := util.WorkshopLanguages(&planConfig)
+	plan.Languages = languages
+	var errs util.Errors
+	errs.Add(setManifestRoute(fs, &planConfig, w.manifest, config.GenDir, generated, result))
+	errs.Add(setWorkshopTitle(fs, &planConfig, languages, generated, result))
+	errs.Add(setWorkshopContent(ctx, fs, &planConfig, languages, generated, result))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		plan.Errors = append(plan.Errors, err.Error())
+	}
+
+	// Report the paths a build would use.
+	display := func(file string) string {
+		if strings.HasPrefix(file, contentDir) {
+			return config.ContentDir + strings.TrimPrefix(file, contentDir)
+		}
+		return file
+	}
+	for i := range plan.Pages {
+		plan.Pages[i].Source = display(plan.Pages[i].Source)
+	}
+	for i := range plan.Assets {
+		plan.Assets[i].Source = display(plan.Assets[i].Source)
+	}
+	for i := range plan.MissingFiles {
+		plan.MissingFiles[i] = display(plan.MissingFiles[i])
+	}
+	for file := range generated.previous {
+		if !generated.files[file] {
+			plan.Removed = append(plan.Removed, config.GenDir+"/"+file)
+		}
+	}
+	sort.Strings(plan.Removed)
+	return plan, nil
+}
+
+// planTheme reports where the theme would come from.
+func planTheme(ctx context.Context, w *workshop, genExists bool) (PlannedSource, error) {
+	config, lock := w.config, w.lock
+	source := PlannedSource{Location: config.ThemeSource.Location, Ref: config.ThemeSource.Ref}
+	if lock.Theme.Matches(config.ThemeSource) {
+		source.Commit = lock.Theme.Commit
+	}
+	switch {
+	case genExists:
+		source.Action = "reuse " + config.GenDir
+	case util.IsLocalSource(w.source(config.ThemeSource).Location):
+		source.Action = "copy"
+	default:
+		if commit, err := util.HeadCommit(w.themeCache); err == nil && source.Commit != "" && commit == source.Commit {
+			source.Action = "use cache " + w.themeCache
+			break
+		}
+		commit, err := util.ResolveRemoteRef(ctx, w.out, config.ThemeSource.Location, lock.Theme.PinnedRef(config.ThemeSource))
+		if err != nil {
+			return source, err
+		}
+		source.Commit = commit
+		source.Action = "clone"
+	}
+	return source, nil
+}
+
+// planContent returns the folder holding the content a build would use,
+// read where it is: ContentDir, or a local source folder a build would copy
+// there. It returns no folder when the content is only known once fetched,
+// resolving the commit a build would fetch instead. A checkout at another
+// commit than dscda.lock pins fails the build and is reported as an error.
+func planContent(ctx context.Context, w *workshop, plan *Plan) (string, error) {
+	config, lock := w.config, w.lock
+	source := &plan.Content
+	*source = PlannedSource{Location: config.ContentSource.Location, Ref: config.ContentSource.Ref}
+	if _, err := w.fs.Stat(config.ContentDir); err == nil {
+		source.Action = "reuse " + config.ContentDir
+		source.Commit, _ = util.HeadCommit(config.ContentDir)
+		if source.Commit != "" {
+			if err := w.checkPinnedContent(source.Commit); err != nil {
+				plan.Errors = append(plan.Errors, err.Error())
+				plan.Unverified = fmt.Sprintf("the pages are those of %s at %s, not of the pinned commit", config.ContentDir, source.Commit)
+			}
+		}
+		return config.ContentDir, nil
+	}
+	if !util.IsOsFs(w.fs) {
+		return "", fmt.Errorf("cannot fetch the content, %s does not exist", config.ContentDir)
+	}
+
+	location := w.source(config.ContentSource).Location
+	if util.IsLocalSource(location) {
+		source.Action = "copy into " + config.ContentDir
+		if info, err := w.fs.Stat(location); err == nil && info.IsDir() {
+			return location, nil
+		}
+		plan.Unverified = fmt.Sprintf("%s is an archive, its pages are known once it is unpacked into %s", config.ContentSource.Location, config.ContentDir)
+		return "", nil
+	}
+	source.Action = "fetch into " + config.ContentDir
+	if lock.Content.Matches(config.ContentSource) && lock.Content.Commit != "" {
+		source.Commit = lock.Content.Commit
+	} else {
+		commit, err := util.ResolveRemoteRef(ctx, w.out, location, config.ContentSource.Ref)
+		if err != nil {
+			return "", err
+		}
+		source.Commit = commit
+	}
+	plan.Unverified = fmt.Sprintf("%s has not been fetched yet, run `dscda init` to fetch it and list its pages and assets", config.ContentDir)
+	return "", nil
+}
+
+// seedTheme marks the pages of the cached theme as present in genDir, as
+// Build copies the theme there before it generates the content.
+func seedTheme(fs afero.Fs, themeCache string, genDir string) error {
+	source := filepath.Join(themeCache, "content")
+	if _, err := fs.Stat(source); err != nil {
+		return nil
+	}
+	return afero.Walk(fs, source, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(source, file)
+		if err != nil {
+			return err
+		}
+		return afero.WriteFile(fs, filepath.Join(genDir, "content", relative), nil, info.Mode())
+	})
+}
+
+// dryRunFs lets a dry run go through the assembly of a build without
+// changing the workshop folder. Files opened for writing are created in an
+// in-memory layer and what is written to them is dropped, so they only
+// record that the file exists. Removed files are hidden.
+type dryRunFs struct {
+	afero.Fs
+	layer   afero.Fs
+	removed map[string]bool
+}
+
+func newDryRunFs(base afero.Fs) *dryRunFs {
+	layer := afero.NewMemMapFs()
+	return &dryRunFs{Fs: afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(base), layer), layer: layer, removed: map[string]bool{}}
+}
+
+func (fs *dryRunFs) Create(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *dryRunFs) Open(name string) (afero.File, error) {
+	if fs.removed[filepath.Clean(name)] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return fs.Fs.Open(name)
+}
+
+func (fs *dryRunFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return fs.Open(name)
+	}
+	if _, err := fs.Stat(name); err != nil && flag&os.O_CREATE == 0 {
+		return nil, err
+	}
+	if err := fs.layer.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return nil, err
+	}
+	file, err := fs.layer.OpenFile(name, flag|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+	delete(fs.removed, filepath.Clean(name))
+	return discardFile{file}, nil
+}
+
+func (fs *dryRunFs) Stat(name string) (os.FileInfo, error) {
+	if fs.removed[filepath.Clean(name)] {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return fs.Fs.Stat(name)
+}
+
+func (fs *dryRunFs) Remove(name string) error {
+	if _, err := fs.Stat(name); err != nil {
+		return err
+	}
+	fs.removed[filepath.Clean(name)] = true
+	if err := fs.layer.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// discardFile drops what is written to it.
+type discardFile struct {
+	afero.File
+}
+
+func (file discardFile) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (file discardFile) WriteAt(p []byte, off int64) (int, error) {
+	return len(p), nil
+}
+
+func (file discardFile) WriteString(s string) (int, error) {
+	return len(s), nil
+}
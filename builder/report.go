@@ -0,0 +1,264 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"workshop-builder/util"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/spf13/afero"
+)
+
+// ReportFileName is written next to the config after every build, for CI
+// dashboards and pull request bots.
+const ReportFileName = "build-report.json"
+
+// ModulePages lists the pages generated for a module type, per language.
+type ModulePages struct {
+	Type  string              `json:"type"`
+	Pages map[string][]string `json:"pages"`
+}
+
+// CopiedAsset is a file copied from the content into GenDir.
+type CopiedAsset struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Size        int64  `json:"size"`
+	// Skipped is set when the asset was unchanged and not copied again.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// SkippedItems counts the pages and assets left as the previous build
+// generated them, as their content did not change.
+type SkippedItems struct {
+	Pages  int `json:"pages"`
+	Assets int `json:"assets"`
+}
+
+// HugoSite counts the pages Hugo built for a language.
+type HugoSite struct {
+	Pages        int `json:"pages"`
+	RegularPages int `json:"regularPages"`
+}
+
+// PhaseTiming is the time a phase of the build took.
+type PhaseTiming struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Result reports what a build did. It is written to ReportFileName. Manifest
+// is the cf push manifest routed to workshopHostname, the one to deploy.
+type Result struct {
+	Started     time.Time           `json:"started"`
+	Seconds     float64             `json:"seconds"`
+	Success     bool                `json:"success"`
+	Error       string              `json:"error,omitempty"`
+	Environment string              `json:"environment,omitempty"`
+	Theme       *util.LockedSource  `json:"theme"`
+	Content     *util.LockedSource  `json:"content"`
+	Languages   []string            `json:"languages"`
+	Modules     []*ModulePages      `json:"modules"`
+	Assets      []CopiedAsset       `json:"assets"`
+	Skipped     SkippedItems        `json:"skipped"`
+	Warnings    []string            `json:"warnings"`
+	Hugo        map[string]HugoSite `json:"hugo"`
+	Manifest    string              `json:"manifest,omitempty"`
+	Phases      []PhaseTiming       `json:"phases"`
+
+	phaseName    string
+	phaseStarted time.Time
+	staging      string
+	genDir       string
+	log          Logger
+	progress     Progress
+	// plan, when set, collects what a dry run would generate.
+	plan *Plan
+}
+
+func newResult(environment string, log Logger, progress Progress) *Result {
+	now := time.Now()
+	return &Result{
+		Started:      now,
+		Environment:  environment,
+		Modules:      []*ModulePages{},
+		Assets:       []CopiedAsset{},
+		Warnings:     []string{},
+		Hugo:         map[string]HugoSite{},
+		Phases:       []PhaseTiming{},
+		phaseName:    "config",
+		phaseStarted: now,
+		log:          log,
+		progress:     progress,
+	}
+}
+
+// stage reports the files written to the staging folder under the GenDir it
+// becomes.
+func (result *Result) stage(staging string, genDir string) {
+	result.staging, result.genDir = staging, genDir
+}
+
+func (result *Result) path(file string) string {
+	if result.staging != "" && strings.HasPrefix(file, result.staging) {
+		return result.genDir + strings.TrimPrefix(file, result.staging)
+	}
+	return file
+}
+
+func (result *Result) emit(event Event) {
+	if result.progress != nil {
+		result.progress.OnEvent(event)
+	}
+}
+
+// phase ends the running phase and starts the one called name.
+func (result *Result) phase(name string) {
+	result.endPhase()
+	result.phaseName = name
+	result.emit(Event{Kind: EventPhase, Phase: name})
+}
+
+func (result *Result) endPhase() {
+	if result.phaseName == "" {
+		return
+	}
+	now := time.Now()
+	result.Phases = append(result.Phases, PhaseTiming{Name: result.phaseName, Seconds: seconds(now.Sub(result.phaseStarted))})
+	result.phaseName = ""
+	result.phaseStarted = now
+}
+
+func (result *Result) module(moduleType string) *ModulePages {
+	for _, module := range result.Modules {
+		if module.Type == moduleType {
+			return module
+		}
+	}
+	module := &ModulePages{Type: moduleType, Pages: map[string][]string{}}
+	result.Modules = append(result.Modules, module)
+	return module
+}
+
+func (result *Result) page(moduleType string, language string, file string) {
+	result.addPage(moduleType, language, file, false)
+}
+
+// skippedPage reports a page left as the previous build generated it.
+func (result *Result) skippedPage(moduleType string, language string, file string) {
+	result.Skipped.Pages++
+	result.addPage(moduleType, language, file, true)
+}
+
+func (result *Result) addPage(moduleType string, language string, file string, skipped bool) {
+	file = result.path(file)
+	module := result.module(moduleType)
+	module.Pages[language] = append(module.Pages[language], file)
+	result.emit(Event{Kind: EventPage, Module: moduleType, Language: language, File: file, Skipped: skipped})
+}
+
+func (result *Result) asset(source string, destination string, size int64) {
+	result.addAsset(source, destination, size, false)
+}
+
+// skippedAsset reports an asset left as the previous build copied it.
+func (result *Result) skippedAsset(source string, destination string, size int64) {
+	result.Skipped.Assets++
+	result.addAsset(source, destination, size, true)
+}
+
+func (result *Result) addAsset(source string, destination string, size int64, skipped bool) {
+	destination = result.path(destination)
+	result.Assets = append(result.Assets, CopiedAsset{Source: source, Destination: destination, Size: size, Skipped: skipped})
+	if result.plan != nil {
+		result.plan.Assets = append(result.plan.Assets, PlannedAsset{Source: source, Destination: destination})
+	}
+	result.emit(Event{Kind: EventAsset, Source: source, File: destination, Size: size, Skipped: skipped})
+}
+
+// plannedPage records how a dry run would generate a page.
+func (result *Result) plannedPage(page PlannedPage) {
+	if result.plan != nil {
+		page.File = result.path(page.File)
+		result.plan.Pages = append(result.plan.Pages, page)
+	}
+}
+
+// missingFile records a file a dry run found missing.
+func (result *Result) missingFile(file string) {
+	if result.plan != nil {
+		result.plan.MissingFiles = append(result.plan.MissingFiles, file)
+	}
+}
+
+// warn records and prints a warning.
+func (result *Result) warn(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	result.log.Printf("Warning %s\n", message)
+	result.addWarning(message)
+}
+
+func (result *Result) addWarning(message string) {
+	result.Warnings = append(result.Warnings, message)
+	result.emit(Event{Kind: EventWarning, Message: message})
+}
+
+// translations adds the missing translations as warnings, and to the plan of
+// a dry run. The translation report prints its own summary.
+func (result *Result) translations(translations *translationReport) {
+	if result.plan != nil {
+		result.plan.MissingTranslations = translations.missing
+	}
+	for _, language := range translations.languages() {
+		for _, page := range translations.missing[language] {
+			if translations.policy == util.FallbackDefault {
+				result.addWarning(fmt.Sprintf("%s is not translated to %s, showing the default language", page, language))
+			} else {
+				result.addWarning(fmt.Sprintf("%s is not translated to %s, left out", page, language))
+			}
+		}
+	}
+}
+
+// hugo records the page counts of every language site Hugo built.
+func (result *Result) hugo(sites *hugolib.HugoSites) {
+	if sites == nil {
+		return
+	}
+	for _, site := range sites.Sites {
+		result.Hugo[site.Language().Lang] = HugoSite{
+			Pages:        len(site.Pages()),
+			RegularPages: len(site.RegularPages()),
+		}
+	}
+}
+
+// finish ends the build, failed with err unless it is nil.
+func (result *Result) finish(err error) {
+	result.endPhase()
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Seconds = seconds(time.Since(result.Started))
+	sort.SliceStable(result.Assets, func(i, j int) bool { return result.Assets[i].Destination < result.Assets[j].Destination })
+}
+
+func (result *Result) write(fs afero.Fs, file string) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fs, file, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", file, err)
+	}
+	return nil
+}
+
+func seconds(duration time.Duration) float64 {
+	return float64(duration.Milliseconds()) / 1000
+}
@@ -0,0 +1,279 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"workshop-builder/util"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// hugoConfigFiles are the site configs Hugo looks for, in its order of
+// precedence.
+var hugoConfigFiles = []string{"config.toml", "config.yaml", "config.yml", "config.json"}
+
+// setSiteConfig rewrites the theme's Hugo config for the workshop: baseURL
+// from workshopHostname, a generated robots.txt, and the languages the
+// workshop is built in, titled in their language and keeping any other
+// settings the theme has for them.
+func setSiteConfig(fs afero.Fs, config *util.WorkshopConfig, languages []string) error {
+	var configFile string
+	for _, name := range hugoConfigFiles {
+		if _, err := fs.Stat(filepath.Join(config.GenDir, name)); err == nil {
+			configFile = filepath.Join(config.GenDir, name)
+			break
+		}
+	}
+	if configFile == "" {
+		return fmt.Errorf("cannot find the Hugo config of the theme in %s", config.GenDir)
+	}
+
+	data, err := afero.ReadFile(fs, configFile)
+	if err != nil {
+		return fmt.Errorf("cannot read %s + %+v", configFile, err)
+	}
+	site := map[string]interface{}{}
+	switch filepath.Ext(configFile) {
+	case ".toml":
+		err = toml.Unmarshal(data, &site)
+	case ".json":
+		err = json.Unmarshal(data, &site)
+	default:
+		err = yaml.Unmarshal(data, &site)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse %s + %+v", configFile, err)
+	}
+
+	// Hugo config keys are case insensitive, the theme may spell them
+	// either way.
+	themeLanguages := map[string]interface{}{}
+	for key, value := range site {
+		switch strings.ToLower(key) {
+		case "languages":
+			if value, ok := value.(map[string]interface{}); ok {
+				themeLanguages = value
+			}
+			delete(site, key)
+		case "defaultcontentlanguage", "baseurl", "enablerobotstxt":
+			delete(site, key)
+		}
+	}
+
+	siteLanguages := map[string]interface{}{}
+	for i, language := range languages {
+		settings := map[string]interface{}{}
+		for code, value := range themeLanguages {
+			if value, ok := value.(map[string]interface{}); ok && strings.EqualFold(code, language) {
+				settings = value
+			}
+		}
+		settings["weight"] = i + 1
+		if _, ok := settings["languageName"]; !ok {
+			settings["languageName"] = util.LanguageName(language)
+		}
+		localization, err := config.LocalizeIn(fs, language)
+		if err != nil {
+			return err
+		}
+		settings["title"] = localization.Title
+		siteLanguages[language] = settings
+	}
+	site["languages"] = siteLanguages
+	site["defaultContentLanguage"] = languages[0]
+	site["baseURL"] = config.BaseURL()
+	site["enableRobotsTXT"] = true
+
+	switch filepath.Ext(configFile) {
+	case ".toml":
+		data, err = toml.Marshal(site)
+	case ".json":
+		data, err = json.MarshalIndent(site, "", "  ")
+	default:
+		data, err = yaml.Marshal(site)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write %s + %+v", configFile, err)
+	}
+	if err := afero.WriteFile(fs, configFile, data, 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", configFile, err)
+	}
+	return nil
+}
+
+// removeThemeHomepages removes the homepages the theme ships for languages
+// the workshop is not built in.
+func removeThemeHomepages(fs afero.Fs, config *util.WorkshopConfig, languages []string) error {
+	homepages, _ := afero.Glob(fs, filepath.Join(config.GenDir, "content", "_index.*.md"))
+	for _, homepage := range homepages {
+		language := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(homepage), "_index."), ".md")
+		if !isWorkshopLanguage(languages, language) {
+			if err := fs.Remove(homepage); err != nil {
+				return fmt.Errorf("cannot remove %s + %+v", homepage, err)
+			}
+		}
+	}
+	return nil
+}
+
+// robotsTemplates are the robots.txt policies, rendered by Hugo.
+var robotsTemplates = map[string]string{
+	util.RobotsAllow:    "User-agent: *\nAllow: /\n{{ if hasPrefix .Site.BaseURL \"http\" }}Sitemap: {{ \"sitemap.xml\" | absURL }}\n{{ end }}",
+	util.RobotsDisallow: "User-agent: *\nDisallow: /\n",
+}
+
+// canonicalHeader links every page to its canonical URL and its
+// translations. It is added to the custom-header partial the theme includes
+// in the <head> of every page.
+const canonicalHeader = `<!-- dscda canonical -->
+{{ if hasPrefix .Site.BaseURL "http" }}<link rel="canonical" href="{{ .Permalink }}">
+{{ range .AllTranslations }}<link rel="alternate" hreflang="{{ .Lang }}" href="{{ .Permalink }}">
+{{ end }}{{ end }}<!-- /dscda canonical -->
+`
+
+// setSiteLayouts writes the robots.txt template and the canonical URL
+// header into the site's layouts, which take precedence over the theme's.
+func setSiteLayouts(fs afero.Fs, config *util.WorkshopConfig) error {
+	layouts := filepath.Join(config.GenDir, "layouts")
+	if err := fs.MkdirAll(filepath.Join(layouts, "partials"), os.FileMode(0755)); err != nil {
+		return fmt.Errorf("cannot create %s + %+v", layouts, err)
+	}
+	robots := filepath.Join(layouts, "robots.txt")
+	if err := afero.WriteFile(fs, robots, []byte(robotsTemplates[config.Robots]), 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", robots, err)
+	}
+
+	// Keep what the site or the theme already puts in the partial.
+	partial := filepath.Join(layouts, "partials", "custom-header.html")
+	existing, err := afero.ReadFile(fs, partial)
+	if os.IsNotExist(err) {
+		themePartials, _ := afero.Glob(fs, filepath.Join(config.GenDir, "themes", "*", "layouts", "partials", "custom-header.html"))
+		if len(themePartials) > 0 {
+			existing, err = afero.ReadFile(fs, themePartials[0])
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read custom-header.html + %+v", err)
+	}
+	header := string(existing)
+	if start := strings.Index(header, "<!-- dscda canonical -->"); start >= 0 {
+		end := strings.Index(header, "<!-- /dscda canonical -->\n")
+		if end > start {
+			header = header[:start] + header[end+len("<!-- /dscda canonical -->\n"):]
+		}
+	}
+	if header != "" && !strings.HasSuffix(header, "\n") {
+		header += "\n"
+	}
+	if err := afero.WriteFile(fs, partial, []byte(header+canonicalHeader), 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", partial, err)
+	}
+	return nil
+}
+
+// setManifestRoute writes the cf push manifest.yml of the workdir to GenDir,
+// pointed at workshopHostname in place of a random route. The manifest.yml of
+// the workdir is left alone, and the path of every application is rebased so
+// the generated manifest pushes the same folder. Other settings and comments
+// are kept. The generated manifest is the one to deploy, it is reported in
+// result.
+func setManifestRoute(fs afero.Fs, config *util.WorkshopConfig, manifestFile string, genDir string, generated *generatedFiles, result *Result) error {
+	route := config.Route()
+	if route == "" {
+		return nil
+	}
+	data, err := afero.ReadFile(fs, manifestFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read %s + %+v", manifestFile, err)
+	}
+	var manifest yaml.Node
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("cannot parse %s + %+v", manifestFile, err)
+	}
+	if len(manifest.Content) == 0 {
+		return nil
+	}
+
+	applications := mappingValue(manifest.Content[0], "applications")
+	if applications == nil || applications.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, application := range applications.Content {
+		if application.Kind != yaml.MappingNode {
+			continue
+		}
+		// cf push resolves the path against the folder of the manifest.
+		path := filepath.Dir(manifestFile)
+		var fields []*yaml.Node
+		for i := 0; i+1 < len(application.Content); i += 2 {
+			switch application.Content[i].Value {
+			case "random-route", "routes":
+			case "path":
+				path = application.Content[i+1].Value
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(filepath.Dir(manifestFile), path)
+				}
+			default:
+				fields = append(fields, application.Content[i], application.Content[i+1])
+			}
+		}
+		if relative, err := filepath.Rel(genDir, path); err == nil {
+			path = filepath.ToSlash(relative)
+		}
+		fields = append(fields, &yaml.Node{Kind: yaml.ScalarNode, Value: "path"}, &yaml.Node{Kind: yaml.ScalarNode, Value: path})
+		routes := &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{{
+			Kind: yaml.MappingNode,
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: "route"},
+				{Kind: yaml.ScalarNode, Value: route},
+			},
+		}}}
+		application.Content = append(fields, &yaml.Node{Kind: yaml.ScalarNode, Value: "routes"}, routes)
+	}
+
+	var output strings.Builder
+	encoder := yaml.NewEncoder(&output)
+	encoder.SetIndent(2)
+	routed := filepath.Join(config.GenDir, "manifest.yml")
+	if err := encoder.Encode(&manifest); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", routed, err)
+	}
+	if err := afero.WriteFile(fs, routed, []byte("---\n"+output.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", routed, err)
+	}
+	generated.add(routed)
+	result.Manifest = result.path(routed)
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isWorkshopLanguage(languages []string, language string) bool {
+	for _, workshopLanguage := range languages {
+		if workshopLanguage == language {
+			return true
+		}
+	}
+	return false
+}
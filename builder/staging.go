@@ -0,0 +1,159 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
+)
+
+// A build assembles GenDir and runs Hugo in staging folders next to GenDir
+// and the output, and only swaps them into place once it succeeded. A build
+// that fails or is canceled leaves the last good GenDir and site as they
+// were.
+const (
+	StagingSuffix = ".staging"
+	// PreviousSuffix is added to the output folder a build replaces, kept
+	// for rollback until the next successful build.
+	PreviousSuffix = ".prev"
+)
+
+// linkedDirs are the folders of GenDir whose files stageDir hard links
+// rather than copies: the pages, assets and static files, which Hugo only
+// reads. Hugo writes elsewhere in its source folder, such as resources/_gen
+// and .hugo_build.lock, and would change the live files through a link.
+var linkedDirs = []string{"content", "static"}
+
+// stageDir prepares staging as a copy of dir. On the disk the files of
+// linkedDirs are hard linked rather than copied, so staging a workshop with
+// large assets is cheap; stagingFs gives a file its own copy before it is
+// written to.
+func stageDir(fs afero.Fs, dir string, staging string) error {
+	if !util.IsOsFs(fs) {
+		return util.CopyDir(fs, dir, staging, nil)
+	}
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(staging, relative)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !inLinkedDir(relative) {
+			return util.CopyFile(fs, file, target)
+		}
+		if err := os.Link(file, target); err != nil {
+			return util.CopyFile(fs, file, target)
+		}
+		return nil
+	})
+}
+
+func inLinkedDir(relative string) bool {
+	for _, linked := range linkedDirs {
+		if strings.HasPrefix(filepath.ToSlash(relative), linked+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// stagingFs writes to a staged folder whose files may be hard links to the
+// live one. Files opened for writing are replaced by a copy of their own
+// first, so the live folder never changes.
+type stagingFs struct {
+	afero.Fs
+}
+
+func (fs stagingFs) Create(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs stagingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if err := fs.unlink(name, flag&os.O_TRUNC == 0); err != nil {
+			return nil, err
+		}
+	}
+	return fs.Fs.OpenFile(name, flag, perm)
+}
+
+// unlink gives the file name an inode of its own, keeping its content unless
+// it is about to be truncated anyway.
+func (fs stagingFs) unlink(name string, keep bool) error {
+	info, err := fs.Fs.Stat(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil || info.IsDir() {
+		return err
+	}
+	var data []byte
+	if keep {
+		if data, err = afero.ReadFile(fs.Fs, name); err != nil {
+			return err
+		}
+	}
+	if err := fs.Fs.Remove(name); err != nil {
+		return err
+	}
+	if !keep {
+		return nil
+	}
+	return afero.WriteFile(fs.Fs, name, data, info.Mode().Perm())
+}
+
+// swapDir moves staging into place as dir, and what dir held to old. When it
+// fails, dir is left as it was.
+func swapDir(fs afero.Fs, staging string, dir string, old string) error {
+	if err := fs.RemoveAll(old); err != nil {
+		return fmt.Errorf("cannot remove %s + %+v", old, err)
+	}
+	_, err := fs.Stat(dir)
+	existed := err == nil
+	if existed {
+		if err := renameDir(fs, dir, old); err != nil {
+			return fmt.Errorf("cannot move %s aside + %+v", dir, err)
+		}
+	}
+	if err := renameDir(fs, staging, dir); err != nil {
+		if existed {
+			_ = renameDir(fs, old, dir)
+		}
+		return fmt.Errorf("cannot move %s into place + %+v", dir, err)
+	}
+	return nil
+}
+
+// unswapDir undoes swapDir, moving dir back to staging and old back into
+// place.
+func unswapDir(fs afero.Fs, staging string, dir string, old string) error {
+	if err := renameDir(fs, dir, staging); err != nil {
+		return err
+	}
+	if _, err := fs.Stat(old); err != nil {
+		return nil
+	}
+	return renameDir(fs, old, dir)
+}
+
+// renameDir renames the folder old. afero's in-memory Rename leaves the
+// files of a folder behind, so they are copied there instead.
+func renameDir(fs afero.Fs, old string, new string) error {
+	if util.IsOsFs(fs) {
+		return fs.Rename(old, new)
+	}
+	if err := util.CopyDir(fs, old, new, nil); err != nil {
+		return err
+	}
+	return fs.RemoveAll(old)
+}
@@ -0,0 +1,245 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
+)
+
+func TestStagingKeepsLiveFolder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "gen")
+	for file, content := range map[string]string{
+		"content/page.en.md":              "live page\n",
+		"content/log.md":                  "live log\n",
+		"resources/_gen/images/small.png": "live image\n",
+		".hugo_build.lock":                "",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	staging := dir + StagingSuffix
+	if err := stageDir(util.OsFs, dir, staging); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hugo writes to its resources and lock file directly, they must not
+	// share the live files.
+	for file, linked := range map[string]bool{"content/page.en.md": true, "resources/_gen/images/small.png": false, ".hugo_build.lock": false} {
+		live, _ := os.Stat(filepath.Join(dir, file))
+		staged, _ := os.Stat(filepath.Join(staging, file))
+		if os.SameFile(live, staged) != linked {
+			t.Errorf("%s linked = %v, want %v", file, !linked, linked)
+		}
+	}
+
+	fs := stagingFs{util.OsFs}
+	if err := afero.WriteFile(fs, filepath.Join(staging, "content/page.en.md"), []byte("staged page\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendToFile(fs, filepath.Join(staging, "content/log.md"), "staged line\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string]string{
+		dir + "/content/page.en.md":     "live page\n",
+		dir + "/content/log.md":         "live log\n",
+		staging + "/content/page.en.md": "staged page\n",
+		staging + "/content/log.md":     "live log\nstaged line\n",
+	} {
+		if got, _ := ioutil.ReadFile(file); string(got) != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+
+	if err := swapDir(util.OsFs, staging, dir, dir+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(dir + "/content/page.en.md"); string(got) != "staged page\n" {
+		t.Errorf("swapped page = %q", got)
+	}
+	if got, _ := ioutil.ReadFile(dir + ".old/content/page.en.md"); string(got) != "live page\n" {
+		t.Errorf("old page = %q", got)
+	}
+	if _, err := os.Stat(staging); !os.IsNotExist(err) {
+		t.Errorf("%s was left behind", staging)
+	}
+
+	if err := unswapDir(util.OsFs, staging, dir, dir+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(dir + "/content/page.en.md"); string(got) != "live page\n" {
+		t.Errorf("restored page = %q", got)
+	}
+}
+
+func TestBuildKeepsFoldersOnFailure(t *testing.T) {
+	work := t.TempDir()
+	for file, content := range map[string]string{
+		"gen/config.toml":                    "title = \"Theme\"\n",
+		"gen/content/.gitkeep":               "",
+		"gen/layouts/index.html":             "{{ .Title }}\n",
+		"gen/layouts/_default/single.html":   "{{ .Content }}\n",
+		"gen/layouts/_default/list.html":     "{{ .Title }}\n",
+		"content/example/example-demo.en.md": "# Example\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(work, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(work, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	build := func(moduleType string) error {
+		config := util.NewDefaultConfig()
+		config.WorkshopSubject = "Cassandra"
+		config.ContentDir = "content"
+		config.GenDir = "gen"
+		config.Modules = []util.ModuleConfig{{
+			Type:    moduleType,
+			Content: []util.ContentConfig{{Name: "example-demo", Filename: "example/example-demo"}},
+		}}
+		_, err := Build(context.Background(), Options{
+			WorkDir: work,
+			Config:  config,
+			Logger:  log.New(ioutil.Discard, "", 0),
+		})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := build("demos"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(work, util.DefaultOutputDir)
+	if _, err := os.Stat(output + PreviousSuffix); err != nil {
+		t.Errorf("the previous site was not kept + %+v", err)
+	}
+
+	genDir, site := snapshot(t, filepath.Join(work, "gen")), snapshot(t, output)
+	if site["index.html"] == "" {
+		t.Fatalf("no site was built: %v", site)
+	}
+	if err := build("workshops"); err == nil {
+		t.Fatal("a build with an undeclared module type succeeded")
+	}
+	if got := snapshot(t, filepath.Join(work, "gen")); !reflect.DeepEqual(got, genDir) {
+		t.Errorf("the failed build changed GenDir")
+	}
+	if got := snapshot(t, output); !reflect.DeepEqual(got, site) {
+		t.Errorf("the failed build changed the site")
+	}
+	for _, staging := range []string{filepath.Join(work, "gen") + StagingSuffix, output + StagingSuffix} {
+		if _, err := os.Stat(staging); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", staging)
+		}
+	}
+}
+
+func TestBuildKeepsGenDirWhenHugoFails(t *testing.T) {
+	work := t.TempDir()
+	for file, content := range map[string]string{
+		"gen/config.toml":                    "title = \"Theme\"\n",
+		"gen/content/.gitkeep":               "",
+		"gen/layouts/index.html":             "{{ with resources.Get \"diagram.png\" }}{{ (.Resize \"8x\").RelPermalink }}{{ end }}\n",
+		"gen/layouts/_default/list.html":     "{{ .Title }}\n",
+		"gen/layouts/_default/single.html":   "{{ with resources.Get \"diagram.png\" }}{{ (.Resize \"4x\").RelPermalink }}{{ end }}{{ if in .RawContent \"FAIL\" }}{{ errorf \"%s asked to fail\" .File.Path }}{{ end }}{{ .Content }}\n",
+		"content/example/example-demo.en.md": "# Example\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(work, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(work, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diagram := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		diagram.Set(x, x, color.RGBA{R: 255, A: 255})
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, diagram); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(work, "gen", "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(work, "gen", "assets", "diagram.png"), encoded.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	build := func() error {
+		config := util.NewDefaultConfig()
+		config.WorkshopSubject = "Cassandra"
+		config.ContentDir = "content"
+		config.GenDir = "gen"
+		config.Modules = []util.ModuleConfig{{
+			Type:    "demos",
+			Content: []util.ContentConfig{{Name: "example-demo", Filename: "example/example-demo"}},
+		}}
+		_, err := Build(context.Background(), Options{
+			WorkDir: work,
+			Config:  config,
+			Logger:  log.New(ioutil.Discard, "", 0),
+		})
+		return err
+	}
+
+	if err := build(); err != nil {
+		t.Fatal(err)
+	}
+	genDir := snapshot(t, filepath.Join(work, "gen"))
+	written := 0
+	for file := range genDir {
+		if strings.HasPrefix(filepath.ToSlash(file), "resources/_gen/") {
+			written++
+		}
+	}
+	if written == 0 {
+		t.Fatalf("Hugo wrote no resources into GenDir: %v", genDir)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(work, "content", "example", "example-demo.en.md"), []byte("# FAIL\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := build(); util.ExitCode(err) != util.ExitHugo {
+		t.Fatalf("a build failing in Hugo = %v", err)
+	}
+	if got := snapshot(t, filepath.Join(work, "gen")); !reflect.DeepEqual(got, genDir) {
+		t.Errorf("the failed Hugo run changed GenDir")
+	}
+}
+
+// snapshot returns the content of the files in dir by their relative path.
+func snapshot(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(file)
+		relative, _ := filepath.Rel(dir, file)
+		files[relative] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
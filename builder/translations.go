@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"workshop-builder/util"
+)
+
+// translationReport collects the pages missing in each language while the
+// content is generated, handled according to translationFallback.
+type translationReport struct {
+	policy  string
+	missing map[string][]string
+}
+
+func newTranslationReport(policy string) *translationReport {
+	return &translationReport{policy: policy, missing: map[string][]string{}}
+}
+
+func (report *translationReport) add(language string, page string) {
+	report.missing[language] = append(report.missing[language], page)
+}
+
+func (report *translationReport) languages() []string {
+	var languages []string
+	for language := range report.missing {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+func (report *translationReport) summary() string {
+	var summary strings.Builder
+	for _, language := range report.languages() {
+		fmt.Fprintf(&summary, "  %s: %s\n", language, strings.Join(report.missing[language], ", "))
+	}
+	return summary.String()
+}
+
+// finish prints which pages were dropped or fell back in which languages,
+// or fails when the policy does not allow missing translations.
+func (report *translationReport) finish(defaultLanguage string, log Logger) error {
+	if len(report.missing) == 0 {
+		return nil
+	}
+	switch report.policy {
+	case util.FallbackFail:
+		return fmt.Errorf("missing translations (translationFallback is %s):\n%s", util.FallbackFail, strings.TrimSuffix(report.summary(), "\n"))
+	case util.FallbackDefault:
+		log.Printf("Missing translations, shown in %s instead:\n%s", util.LanguageName(defaultLanguage), report.summary())
+	default:
+		log.Printf("Missing translations, left out of those languages:\n%s", report.summary())
+	}
+	return nil
+}
@@ -1,17 +1,41 @@
 package clean
 
 import (
-	"fmt"
-	"os"
+	"errors"
+
+	"workshop-builder/util"
+
+	"github.com/spf13/afero"
 )
 
-func CleanCmd() {
-	if err := os.RemoveAll("paceWorkshopContent/"); err != nil {
-		fmt.Println("Error " + err.Error())
-		return
+func CleanCmd(configPath string) error {
+	configPath, err := util.FindConfig(configPath)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+	config, err := util.DetermineConfig(configPath)
+	if errors.Is(err, util.ErrConfigNotFound) {
+		config = util.NewDefaultConfig()
+	} else if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+
+	return cleanWorkshop(util.OsFs, config)
+}
+
+// cleanWorkshop removes the content, GenDir and the theme cache of config
+// from fs. Folders that hold the working directory are refused.
+func cleanWorkshop(fs afero.Fs, config *util.WorkshopConfig) error {
+	for _, dir := range []string{config.ContentDir, config.GenDir} {
+		if err := util.CheckOwnFolder(dir, "."); err != nil {
+			return util.WithExitCode(util.ExitConfig, err)
+		}
+	}
+	if err := fs.RemoveAll(config.ContentDir + "/"); err != nil {
+		return err
 	}
-	if err := os.RemoveAll("workshopGen/"); err != nil {
-		fmt.Println("Error " + err.Error())
-		return
+	if err := fs.RemoveAll(config.GenDir + "/"); err != nil {
+		return err
 	}
+	return fs.RemoveAll(util.CacheDir + "/")
 }
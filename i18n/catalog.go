@@ -0,0 +1,282 @@
+package i18n
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// catalog holds the translation units of a set of content files for one
+// target language, independent of the exchange format.
+type catalog struct {
+	SourceLanguage string
+	TargetLanguage string
+	Files          []catalogFile
+}
+
+// catalogFile holds the units of one source file, named relative to the
+// content folder, e.g. example/example-demo.en.md.
+type catalogFile struct {
+	Original string
+	Units    []catalogUnit
+}
+
+type catalogUnit struct {
+	ID     string
+	Kind   string
+	Line   int
+	Source string
+	Target string
+}
+
+// Exchange formats of `dscda i18n export`.
+const (
+	FormatXLIFF = "xliff"
+	FormatPO    = "po"
+)
+
+func catalogExtension(format string) string {
+	if format == FormatPO {
+		return ".po"
+	}
+	return ".xlf"
+}
+
+// readCatalog reads an XLIFF or PO file, chosen by file extension.
+func readCatalog(path string, r io.Reader) (*catalog, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlf", ".xliff":
+		return readXLIFF(r)
+	case ".po":
+		return readPO(r)
+	}
+	return nil, fmt.Errorf("cannot tell the format of %s, expected .xlf, .xliff or .po", path)
+}
+
+type xliffDocument struct {
+	XMLName xml.Name    `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string      `xml:"version,attr"`
+	SrcLang string      `xml:"srcLang,attr"`
+	TrgLang string      `xml:"trgLang,attr,omitempty"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	ID       string      `xml:"id,attr"`
+	Original string      `xml:"original,attr"`
+	Units    []xliffUnit `xml:"unit"`
+}
+
+type xliffUnit struct {
+	ID      string       `xml:"id,attr"`
+	Name    string       `xml:"name,attr,omitempty"`
+	Notes   []string     `xml:"notes>note,omitempty"`
+	Segment xliffSegment `xml:"segment"`
+}
+
+type xliffSegment struct {
+	State  string  `xml:"state,attr,omitempty"`
+	Source string  `xml:"source"`
+	Target *string `xml:"target"`
+}
+
+func writeXLIFF(w io.Writer, c *catalog) error {
+	document := xliffDocument{Version: "2.0", SrcLang: c.SourceLanguage, TrgLang: c.TargetLanguage}
+	for i, file := range c.Files {
+		xf := xliffFile{ID: fmt.Sprintf("f%d", i+1), Original: file.Original}
+		for _, unit := range file.Units {
+			xu := xliffUnit{
+				ID:      unit.ID,
+				Name:    unit.Kind,
+				Notes:   []string{fmt.Sprintf("%s line %d", unit.Kind, unit.Line)},
+				Segment: xliffSegment{State: "initial", Source: unit.Source},
+			}
+			if unit.Target != "" {
+				target := unit.Target
+				xu.Segment.State = "translated"
+				xu.Segment.Target = &target
+			}
+			xf.Units = append(xf.Units, xu)
+		}
+		document.Files = append(document.Files, xf)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func readXLIFF(r io.Reader) (*catalog, error) {
+	var document xliffDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("cannot parse XLIFF + %+v", err)
+	}
+	if document.Version != "2.0" {
+		return nil, fmt.Errorf("unsupported XLIFF version %q, expected 2.0", document.Version)
+	}
+	c := &catalog{SourceLanguage: document.SrcLang, TargetLanguage: document.TrgLang}
+	for _, xf := range document.Files {
+		file := catalogFile{Original: xf.Original}
+		for _, xu := range xf.Units {
+			unit := catalogUnit{ID: xu.ID, Kind: xu.Name, Source: xu.Segment.Source}
+			if xu.Segment.Target != nil {
+				unit.Target = *xu.Segment.Target
+			}
+			file.Units = append(file.Units, unit)
+		}
+		c.Files = append(c.Files, file)
+	}
+	return c, nil
+}
+
+func writePO(w io.Writer, c *catalog) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "msgid \"\"\nmsgstr \"\"\n")
+	fmt.Fprintf(out, "%s\n", poString("Content-Type: text/plain; charset=UTF-8\n"))
+	fmt.Fprintf(out, "%s\n", poString("Language: "+c.TargetLanguage+"\n"))
+	fmt.Fprintf(out, "%s\n", poString("X-Source-Language: "+c.SourceLanguage+"\n"))
+	for _, file := range c.Files {
+		for _, unit := range file.Units {
+			fmt.Fprintf(out, "\n#. %s\n", unit.Kind)
+			fmt.Fprintf(out, "#: %s:%d\n", file.Original, unit.Line)
+			fmt.Fprintf(out, "msgctxt %s\n", poString(file.Original+"#"+unit.ID))
+			fmt.Fprintf(out, "msgid %s\n", poString(unit.Source))
+			fmt.Fprintf(out, "msgstr %s\n", poString(unit.Target))
+		}
+	}
+	return out.Flush()
+}
+
+// poString quotes s for a PO file, splitting it after each newline the way
+// gettext tools do.
+func poString(s string) string {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		return poQuote(s)
+	}
+	lines := []string{`""`}
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			lines = append(lines, poQuote(line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func poQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
+func readPO(r io.Reader) (*catalog, error) {
+	c := &catalog{}
+	files := map[string]int{}
+	var (
+		keyword             string
+		context, id, target string
+		lineNumber          int
+	)
+	flush := func() error {
+		if id == "" {
+			// The header entry, holding the catalog metadata.
+			for _, line := range strings.Split(target, "\n") {
+				field := strings.SplitN(line, ":", 2)
+				if len(field) < 2 {
+					continue
+				}
+				switch strings.TrimSpace(field[0]) {
+				case "Language":
+					c.TargetLanguage = strings.TrimSpace(field[1])
+				case "X-Source-Language":
+					c.SourceLanguage = strings.TrimSpace(field[1])
+				}
+			}
+		} else {
+			separator := strings.LastIndex(context, "#")
+			if separator < 0 {
+				return fmt.Errorf("line %d: msgctxt %q does not name a content file and unit", lineNumber, context)
+			}
+			original := context[:separator]
+			index, ok := files[original]
+			if !ok {
+				index = len(c.Files)
+				files[original] = index
+				c.Files = append(c.Files, catalogFile{Original: original})
+			}
+			c.Files[index].Units = append(c.Files[index].Units, catalogUnit{ID: context[separator+1:], Source: id, Target: target})
+		}
+		context, id, target = "", "", ""
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	seenEntry := false
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, `"`) {
+			value, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %+v", lineNumber, err)
+			}
+			switch keyword {
+			case "msgctxt":
+				context += value
+			case "msgid":
+				id += value
+			case "msgstr":
+				target += value
+			}
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		word := fields[0]
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: %q has no value", lineNumber, word)
+		}
+		value, err := strconv.Unquote(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %+v", lineNumber, err)
+		}
+		if (word == "msgctxt" || (word == "msgid" && keyword != "msgctxt")) && seenEntry {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		switch word {
+		case "msgctxt":
+			context = value
+		case "msgid":
+			id = value
+		case "msgstr":
+			target = value
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", lineNumber, word)
+		}
+		keyword = word
+		seenEntry = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if seenEntry {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCatalogRoundTrip exports a file, translates every unit to its source
+// and imports it again, which must give back the file byte for byte.
+func TestCatalogRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatXLIFF, FormatPO} {
+		t.Run(format, func(t *testing.T) {
+			contentDir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(contentDir, "example"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(contentDir, "example/demo.en.md"), []byte(sampleMarkdown), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			exported, err := exportFile(contentDir, "example/demo", "en", "de")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range exported.Units {
+				exported.Units[i].Target = exported.Units[i].Source
+			}
+			c := &catalog{SourceLanguage: "en", TargetLanguage: "de", Files: []catalogFile{*exported}}
+
+			path := filepath.Join(t.TempDir(), "de"+catalogExtension(format))
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format == FormatPO {
+				err = writePO(f, c)
+			} else {
+				err = writeXLIFF(f, c)
+			}
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err = os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := readCatalog(path, f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read.SourceLanguage != "en" || read.TargetLanguage != "de" || len(read.Files) != 1 || len(read.Files[0].Units) != len(exported.Units) {
+				t.Fatalf("read %+v", read)
+			}
+			for i, unit := range read.Files[0].Units {
+				if want := exported.Units[i]; unit.ID != want.ID || unit.Source != want.Source || unit.Target != want.Target {
+					t.Errorf("unit %d = %+v, want %+v", i, unit, want)
+				}
+			}
+
+			if err := importCatalog(contentDir, path); err != nil {
+				t.Fatal(err)
+			}
+			imported, err := ioutil.ReadFile(filepath.Join(contentDir, "example/demo.de.md"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(imported) != sampleMarkdown {
+				t.Errorf("imported %q, want %q", imported, sampleMarkdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package i18n
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// segment is a piece of a Markdown document. Segments with a kind hold text
+// offered for translation, rendered between their prefix and suffix; the
+// others, such as code blocks and blank lines, are kept verbatim in text.
+type segment struct {
+	kind   string
+	prefix string
+	text   string
+	suffix string
+	line   int
+	// raw is the front matter title as written, escapes included; text
+	// holds its value.
+	raw string
+}
+
+const (
+	kindTitle     = "title"
+	kindHeading   = "heading"
+	kindParagraph = "paragraph"
+	kindListItem  = "list-item"
+	kindQuote     = "quote"
+)
+
+var (
+	frontMatterTitle = regexp.MustCompile(`^(title\s*[=:]\s*["']?)(.*?)(["']?\s*)$`)
+	headingLine      = regexp.MustCompile(`^( {0,3}#{1,6}\s+)(.*?)(\s*#*\s*)$`)
+	ruleLine         = regexp.MustCompile(`^ {0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	listItemLine     = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?)(.*?)(\s*)$`)
+	quoteLine        = regexp.MustCompile(`^(\s*>\s?)(.*?)(\s*)$`)
+)
+
+// parseMarkdown splits a document into segments. Headings, paragraphs, list
+// items, block quotes and the front matter title are translatable; front
+// matter, fenced and indented code, HTML and shortcode lines, tables and
+// rules are not.
+func parseMarkdown(data string) []segment {
+	lines := strings.SplitAfter(data, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var segments []segment
+	verbatim := func(i int) {
+		segments = append(segments, segment{text: lines[i], line: i + 1})
+	}
+	unit := func(kind string, i int, match []string) {
+		segments = append(segments, segment{kind: kind, prefix: match[1], text: match[2], suffix: match[3] + lineEnding(lines[i]), line: i + 1})
+	}
+
+	i := 0
+	if len(lines) > 0 {
+		if marker := strings.TrimSpace(lines[0]); marker == "+++" || marker == "---" {
+			verbatim(0)
+			for i = 1; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == marker {
+					verbatim(i)
+					i++
+					break
+				}
+				if match := frontMatterTitle.FindStringSubmatch(strings.TrimRight(lines[i], "\r\n")); match != nil && match[2] != "" {
+					unit(kindTitle, i, match)
+					title := &segments[len(segments)-1]
+					title.raw = title.text
+					title.text = unquoteTitle(title.raw, titleQuote(*title), marker == "+++")
+				} else {
+					verbatim(i)
+				}
+			}
+		}
+	}
+
+	previousBlank := true
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence := trimmed[:3]
+			verbatim(i)
+			for i++; i < len(lines); i++ {
+				verbatim(i)
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+			}
+		case trimmed == "":
+			verbatim(i)
+			previousBlank = true
+			continue
+		case previousBlank && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")):
+			// Indented code runs until the next unindented line.
+			for ; i < len(lines); i++ {
+				next := strings.TrimRight(lines[i], "\r\n")
+				if strings.TrimSpace(next) != "" && !strings.HasPrefix(next, "    ") && !strings.HasPrefix(next, "\t") {
+					i--
+					break
+				}
+				verbatim(i)
+			}
+		case strings.HasPrefix(trimmed, "<") || strings.HasPrefix(trimmed, "{{") || strings.HasPrefix(trimmed, "|") || ruleLine.MatchString(line):
+			verbatim(i)
+		case headingLine.MatchString(line):
+			unit(kindHeading, i, headingLine.FindStringSubmatch(line))
+		case listItemLine.MatchString(line):
+			unit(kindListItem, i, listItemLine.FindStringSubmatch(line))
+		case quoteLine.MatchString(line):
+			unit(kindQuote, i, quoteLine.FindStringSubmatch(line))
+		default:
+			// A paragraph runs until a blank line or the start of another
+			// block.
+			start := i
+			for i+1 < len(lines) && isParagraphContinuation(strings.TrimRight(lines[i+1], "\r\n")) {
+				i++
+			}
+			text := strings.TrimRight(strings.Join(lines[start:i+1], ""), "\r\n")
+			segments = append(segments, segment{kind: kindParagraph, text: text, suffix: lineEnding(lines[i]), line: start + 1})
+		}
+		previousBlank = false
+	}
+	return segments
+}
+
+func isParagraphContinuation(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "",
+		strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"),
+		strings.HasPrefix(trimmed, "<"), strings.HasPrefix(trimmed, "{{"), strings.HasPrefix(trimmed, "|"),
+		ruleLine.MatchString(line), headingLine.MatchString(line), listItemLine.MatchString(line), quoteLine.MatchString(line):
+		return false
+	}
+	return true
+}
+
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// units returns the translatable segments of a document.
+func units(segments []segment) []segment {
+	var translatable []segment
+	for _, segment := range segments {
+		if segment.kind != "" && strings.TrimSpace(segment.text) != "" {
+			translatable = append(translatable, segment)
+		}
+	}
+	return translatable
+}
+
+// renderMarkdown writes the segments back, with the text of the n-th
+// translatable segment replaced by translate(n, segment). Segments whose text
+// is unchanged are written as they were.
+func renderMarkdown(segments []segment, translate func(int, segment) string) string {
+	var document strings.Builder
+	n := 0
+	for _, segment := range segments {
+		if segment.kind == "" || strings.TrimSpace(segment.text) == "" {
+			document.WriteString(segment.written())
+			continue
+		}
+		text := translate(n, segment)
+		n++
+		switch {
+		case text == segment.text:
+			document.WriteString(segment.written())
+		case segment.kind == kindTitle:
+			document.WriteString(renderTitle(segment, text))
+		default:
+			document.WriteString(segment.prefix + text + segment.suffix)
+		}
+	}
+	return document.String()
+}
+
+// written returns the segment as it was written in the document.
+func (segment segment) written() string {
+	if segment.kind == kindTitle {
+		return segment.prefix + segment.raw + segment.suffix
+	}
+	return segment.prefix + segment.text + segment.suffix
+}
+
+// titleQuote returns the quote around the front matter title, if any.
+func titleQuote(title segment) string {
+	if quote := title.prefix[len(title.prefix)-1:]; (quote == `"` || quote == "'") && strings.HasPrefix(title.suffix, quote) {
+		return quote
+	}
+	return ""
+}
+
+// unquoteTitle returns the value of the front matter title raw, written
+// between quote in TOML or YAML front matter.
+func unquoteTitle(raw string, quote string, toml bool) string {
+	switch {
+	case quote == `"`:
+		if value, err := strconv.Unquote(`"` + raw + `"`); err == nil {
+			return value
+		}
+	case quote == "'" && !toml:
+		return strings.ReplaceAll(raw, "''", "'")
+	}
+	return raw
+}
+
+// renderTitle writes the front matter title with the value title, quoted the
+// way the source is when that can hold it and in double quotes otherwise.
+func renderTitle(source segment, title string) string {
+	quote := titleQuote(source)
+	key := strings.TrimSuffix(source.prefix, quote)
+	rest := strings.TrimPrefix(source.suffix, quote)
+	toml := strings.Contains(key, "=")
+	switch {
+	case quote == "'" && !toml:
+		return key + "'" + strings.ReplaceAll(title, "'", "''") + "'" + rest
+	case quote == "'" && !strings.ContainsAny(title, "'\n"):
+		return key + "'" + title + "'" + rest
+	case quote == "" && !toml && plainYAML(title):
+		return key + title + rest
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\t", `\t`).Replace(title)
+	return key + `"` + escaped + `"` + rest
+}
+
+// plainYAML reports whether title can be written as a plain YAML scalar.
+func plainYAML(title string) bool {
+	return title == strings.TrimSpace(title) &&
+		!strings.ContainsAny(title, ":#'\"[]{},&*!|>%@`") &&
+		!strings.HasPrefix(title, "-") && !strings.HasPrefix(title, "?")
+}
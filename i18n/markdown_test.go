@@ -0,0 +1,95 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const sampleMarkdown = "+++\r\n" +
+	"title = \"Say \\\"hello\\\"\"\r\n" +
+	"weight = 2\r\n" +
+	"+++\r\n" +
+	"\r\n" +
+	"# Getting started #\r\n" +
+	"\r\n" +
+	"A paragraph that runs\r\n" +
+	"over two lines.\r\n" +
+	"\r\n" +
+	"```bash\r\n" +
+	"# not a heading\r\n" +
+	"echo \"hello\"\r\n" +
+	"```\r\n" +
+	"\r\n" +
+	"- [x] a task\r\n" +
+	"- an item\r\n" +
+	"\r\n" +
+	"> a quote\r\n" +
+	"\r\n" +
+	"    indented code\r\n" +
+	"\r\n" +
+	"| a | table |\r\n" +
+	"{{< shortcode >}}\r\n" +
+	"---\r\n" +
+	"Last line without a newline"
+
+func TestRenderMarkdownRoundTrip(t *testing.T) {
+	for name, document := range map[string]string{
+		"crlf": sampleMarkdown,
+		"lf":   strings.ReplaceAll(sampleMarkdown, "\r\n", "\n"),
+		"yaml": "---\ntitle: 'It''s here'\n---\n\nText\n",
+	} {
+		segments := parseMarkdown(document)
+		if got := renderMarkdown(segments, func(_ int, source segment) string { return source.text }); got != document {
+			t.Errorf("%s: rendered %q, want %q", name, got, document)
+		}
+	}
+}
+
+func TestParseMarkdownUnits(t *testing.T) {
+	var got []string
+	for _, unit := range units(parseMarkdown(sampleMarkdown)) {
+		got = append(got, unit.kind+": "+unit.text)
+	}
+	want := []string{
+		`title: Say "hello"`,
+		"heading: Getting started",
+		"paragraph: A paragraph that runs\r\nover two lines.",
+		"list-item: a task",
+		"list-item: an item",
+		"quote: a quote",
+		"paragraph: Last line without a newline",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("units\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRenderTitleEscapes(t *testing.T) {
+	const title = `Il dit "l'heure": #1 \o/`
+	for _, frontMatter := range []string{
+		"+++\ntitle = \"Source\"\n+++\n",
+		"+++\ntitle = 'Source'\n+++\n",
+		"---\ntitle: Source\n---\n",
+		"---\ntitle: 'Source'\n---\n",
+		"---\ntitle: \"Source\"\n---\n",
+	} {
+		document := renderMarkdown(parseMarkdown(frontMatter), func(int, segment) string { return title })
+		body := strings.Split(document, "\n")[1]
+		var values map[string]string
+		var err error
+		if strings.HasPrefix(frontMatter, "+++") {
+			err = toml.Unmarshal([]byte(body), &values)
+		} else {
+			err = yaml.Unmarshal([]byte(body), &values)
+		}
+		if err != nil || values["title"] != title {
+			t.Errorf("%q rendered as %q = %q + %v", frontMatter, body, values["title"], err)
+		}
+		if units := units(parseMarkdown(document)); len(units) != 1 || units[0].text != title {
+			t.Errorf("%q does not parse back to the title: %+v", body, units)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Translation coverage of the workshop content
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"workshop-builder/util"
+)
+
+const (
+	statusSource  = "source"
+	statusCurrent = "current"
+	statusStale   = "stale"
+	statusMissing = "missing"
+)
+
+// Formats of `dscda i18n status`.
+const (
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+type translationStatus struct {
+	Status  string     `json:"status"`
+	Updated *time.Time `json:"updated,omitempty"`
+}
+
+type moduleStatus struct {
+	Module    string                       `json:"module"`
+	File      string                       `json:"file"`
+	Languages map[string]translationStatus `json:"languages"`
+}
+
+type statusReport struct {
+	DefaultLanguage string         `json:"defaultLanguage"`
+	Languages       []string       `json:"languages"`
+	Modules         []moduleStatus `json:"modules"`
+}
+
+func StatusCmd(configPath string, all bool, format string) error {
+	if format != FormatTable && format != FormatJSON && format != FormatMarkdown {
+		return fmt.Errorf("unknown format %q, use %s, %s or %s", format, FormatTable, FormatJSON, FormatMarkdown)
+	}
+	config, err := loadConfig(configPath, all)
+	if err != nil {
+		return err
+	}
+
+	var report *statusReport
+	if all {
+		report, err = contentStatus(config.ContentDir)
+	} else {
+		report, err = workshopStatus(config)
+	}
+	if err != nil {
+		return util.WithExitCode(util.ExitContent, err)
+	}
+
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case FormatMarkdown:
+		printMarkdown(report)
+	default:
+		printTable(report)
+	}
+	return nil
+}
+
+// loadConfig reads the workshop config and checks the content has been
+// fetched. Commands that do not need the modules may run without a config,
+// on the default content folder.
+func loadConfig(configPath string, allowDefault bool) (*util.WorkshopConfig, error) {
+	configPath, err := util.FindConfig(configPath)
+	if err != nil {
+		return nil, util.WithExitCode(util.ExitConfig, err)
+	}
+	config, err := util.DetermineConfig(configPath)
+	if allowDefault && errors.Is(err, util.ErrConfigNotFound) {
+		config, err = util.NewDefaultConfig(), nil
+	}
+	if err != nil {
+		return nil, util.WithExitCode(util.ExitConfig, err)
+	}
+	if _, err := os.Stat(config.ContentDir); os.IsNotExist(err) {
+		return nil, util.WithExitCode(util.ExitContent, fmt.Errorf("%s has not been fetched yet (run `dscda init`)", config.ContentDir))
+	}
+	return config, nil
+}
+
+// workshopStatus reports on the content entries of the workshop's modules,
+// in the languages the workshop is built in.
+func workshopStatus(config *util.WorkshopConfig) (*statusReport, error) {
+	report := &statusReport{Languages: util.WorkshopLanguages(config)}
+	for _, module := range config.Modules {
+		for _, content := range module.Content {
+			report.Modules = append(report.Modules, moduleStatus{
+				Module: module.Type + "/" + filepath.Base(content.Filename),
+				File:   content.Filename,
+			})
+		}
+	}
+	return report, report.resolve(config.ContentDir)
+}
+
+// contentStatus reports on every translated Markdown file of the content
+// checkout, in every language found.
+func contentStatus(contentDir string) (*statusReport, error) {
+	files := map[string]bool{}
+	languages := map[string]bool{}
+	err := filepath.Walk(contentDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		relative, _ := filepath.Rel(contentDir, path)
+		if base, language, ok := util.SplitLanguage(filepath.ToSlash(relative)); ok && !info.IsDir() {
+			files[base] = true
+			languages[language] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &statusReport{Languages: util.SortLanguages(languages)}
+	for file := range files {
+		report.Modules = append(report.Modules, moduleStatus{Module: file, File: file})
+	}
+	sort.Slice(report.Modules, func(i, j int) bool { return report.Modules[i].Module < report.Modules[j].Module })
+	return report, report.resolve(contentDir)
+}
+
+// resolve fills in the status of every module in every language. A
+// translation is stale when its last commit is older than the last commit of
+// the default language version it was translated from.
+func (report *statusReport) resolve(contentDir string) error {
+	report.DefaultLanguage = report.Languages[0]
+
+	var files []string
+	for _, module := range report.Modules {
+		for _, language := range report.Languages {
+			file := module.File + "." + language + ".md"
+			if _, err := os.Stat(filepath.Join(contentDir, filepath.FromSlash(file))); err == nil {
+				files = append(files, file)
+			}
+		}
+	}
+	updated, err := util.LastCommitTimes(contentDir, files)
+	if err != nil {
+		// Content from a local folder or tarball has no history, only
+		// missing files can be reported.
+		updated = map[string]time.Time{}
+	}
+
+	for i := range report.Modules {
+		module := &report.Modules[i]
+		module.Languages = map[string]translationStatus{}
+		source, sourceCommitted := updated[module.File+"."+report.DefaultLanguage+".md"]
+		for _, language := range report.Languages {
+			file := module.File + "." + language + ".md"
+			if _, err := os.Stat(filepath.Join(contentDir, filepath.FromSlash(file))); err != nil {
+				module.Languages[language] = translationStatus{Status: statusMissing}
+				continue
+			}
+			status := translationStatus{Status: statusCurrent}
+			if when, ok := updated[file]; ok {
+				status.Updated = &when
+				if language != report.DefaultLanguage && sourceCommitted && when.Before(source) {
+					status.Status = statusStale
+				}
+			}
+			if language == report.DefaultLanguage {
+				status.Status = statusSource
+			}
+			module.Languages[language] = status
+		}
+	}
+	return nil
+}
+
+func (report *statusReport) count(status string) int {
+	count := 0
+	for _, module := range report.Modules {
+		for _, language := range report.Languages {
+			if module.Languages[language].Status == status {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func (report *statusReport) summary() string {
+	return fmt.Sprintf("%d modules, %d missing and %d stale translations", len(report.Modules), report.count(statusMissing), report.count(statusStale))
+}
+
+func printTable(report *statusReport) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(writer, "MODULE")
+	for _, language := range report.Languages {
+		fmt.Fprint(writer, "\t"+strings.ToUpper(language))
+	}
+	fmt.Fprintln(writer)
+	for _, module := range report.Modules {
+		fmt.Fprint(writer, module.Module)
+		for _, language := range report.Languages {
+			fmt.Fprint(writer, "\t"+module.Languages[language].Status)
+		}
+		fmt.Fprintln(writer)
+	}
+	writer.Flush()
+	fmt.Println()
+	fmt.Println(report.summary())
+}
+
+var markdownStatus = map[string]string{
+	statusSource:  "✅ source",
+	statusCurrent: "✅",
+	statusStale:   "⚠️ stale",
+	statusMissing: "❌ missing",
+}
+
+func printMarkdown(report *statusReport) {
+	fmt.Print("| Module |")
+	for _, language := range report.Languages {
+		fmt.Printf(" %s |", util.LanguageName(language))
+	}
+	fmt.Print("\n| --- |")
+	for range report.Languages {
+		fmt.Print(" --- |")
+	}
+	fmt.Println()
+	for _, module := range report.Modules {
+		fmt.Printf("| `%s` |", module.Module)
+		for _, language := range report.Languages {
+			fmt.Printf(" %s |", markdownStatus[module.Languages[language].Status])
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	fmt.Println(report.summary())
+}
@@ -0,0 +1,215 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"workshop-builder/util"
+)
+
+func ExportCmd(configPath string, targetLanguages []string, format string, outputDir string) error {
+	if format != FormatXLIFF && format != FormatPO {
+		return fmt.Errorf("unknown format %q, use %s or %s", format, FormatXLIFF, FormatPO)
+	}
+	config, err := loadConfig(configPath, false)
+	if err != nil {
+		return err
+	}
+	languages := util.WorkshopLanguages(config)
+	sourceLanguage := languages[0]
+	if len(targetLanguages) == 0 {
+		targetLanguages = languages[1:]
+	}
+	if len(targetLanguages) == 0 {
+		return util.WithExitCode(util.ExitConfig, fmt.Errorf("the workshop has no other languages than %s, pass the languages to translate to with --lang", sourceLanguage))
+	}
+	if err := os.MkdirAll(outputDir, os.FileMode(0755)); err != nil {
+		return err
+	}
+
+	var files []string
+	for _, module := range config.Modules {
+		for _, content := range module.Content {
+			if !containsString(files, content.Filename) {
+				files = append(files, content.Filename)
+			}
+		}
+	}
+
+	for _, targetLanguage := range targetLanguages {
+		c := &catalog{SourceLanguage: sourceLanguage, TargetLanguage: targetLanguage}
+		unitCount := 0
+		for _, file := range files {
+			catalogFile, err := exportFile(config.ContentDir, file, sourceLanguage, targetLanguage)
+			if os.IsNotExist(err) {
+				fmt.Printf("Warning %s.%s.md not found, skipped\n", file, sourceLanguage)
+				continue
+			}
+			if err != nil {
+				return util.WithExitCode(util.ExitContent, err)
+			}
+			c.Files = append(c.Files, *catalogFile)
+			unitCount += len(catalogFile.Units)
+		}
+
+		output := filepath.Join(outputDir, targetLanguage+catalogExtension(format))
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		if format == FormatPO {
+			err = writePO(f, c)
+		} else {
+			err = writeXLIFF(f, c)
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("cannot write %s + %+v", output, err)
+		}
+		fmt.Printf("Wrote %s (%d units from %d files)\n", output, unitCount, len(c.Files))
+	}
+	return nil
+}
+
+// exportFile extracts the units of the source version of file. Existing
+// translations are filled in as targets when their structure still matches
+// the source, so translators only see what is left; text identical to the
+// source counts as untranslated.
+func exportFile(contentDir string, file string, sourceLanguage string, targetLanguage string) (*catalogFile, error) {
+	original := file + "." + sourceLanguage + ".md"
+	data, err := ioutil.ReadFile(filepath.Join(contentDir, filepath.FromSlash(original)))
+	if err != nil {
+		return nil, err
+	}
+	sources := units(parseMarkdown(string(data)))
+
+	var targets []segment
+	if translation, err := ioutil.ReadFile(filepath.Join(contentDir, filepath.FromSlash(file+"."+targetLanguage+".md"))); err == nil {
+		targets = units(parseMarkdown(string(translation)))
+		if !sameStructure(sources, targets) {
+			targets = nil
+		}
+	}
+
+	catalogFile := &catalogFile{Original: original}
+	for n, source := range sources {
+		unit := catalogUnit{ID: unitID(n), Kind: source.kind, Line: source.line, Source: source.text}
+		if targets != nil && targets[n].text != source.text {
+			unit.Target = targets[n].text
+		}
+		catalogFile.Units = append(catalogFile.Units, unit)
+	}
+	return catalogFile, nil
+}
+
+func ImportCmd(configPath string, paths []string) error {
+	config, err := loadConfig(configPath, true)
+	if err != nil {
+		return err
+	}
+	var errs util.Errors
+	for _, path := range paths {
+		errs.Add(importCatalog(config.ContentDir, path))
+	}
+	return util.WithExitCode(util.ExitContent, errs.Err())
+}
+
+// importCatalog writes <file>.<lang>.md for every file of the catalog with
+// translated units. The source version is rendered again with the
+// translations in place, keeping its Markdown structure and code blocks;
+// units missing a translation, or translated from a source that has changed
+// since, stay in the source language.
+func importCatalog(contentDir string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	c, err := readCatalog(path, f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("cannot read %s + %+v", path, err)
+	}
+	if c.TargetLanguage == "" {
+		return fmt.Errorf("%s does not name its target language", path)
+	}
+
+	for _, file := range c.Files {
+		base, _, ok := util.SplitLanguage(file.Original)
+		cleaned := filepath.Clean(filepath.FromSlash(file.Original))
+		if !ok || filepath.IsAbs(cleaned) || strings.HasPrefix(cleaned, "..") {
+			return fmt.Errorf("%s: %q is not a content file", path, file.Original)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(contentDir, cleaned))
+		if err != nil {
+			return fmt.Errorf("%s: cannot read the source of %s + %+v", path, file.Original, err)
+		}
+
+		targets := map[string]catalogUnit{}
+		for _, unit := range file.Units {
+			targets[unit.ID] = unit
+		}
+		translated, outdated := 0, 0
+		segments := parseMarkdown(string(data))
+		document := renderMarkdown(segments, func(n int, source segment) string {
+			unit, ok := targets[unitID(n)]
+			if !ok || strings.TrimSpace(unit.Target) == "" {
+				return source.text
+			}
+			if normalizeNewlines(unit.Source) != normalizeNewlines(source.text) {
+				outdated++
+				return source.text
+			}
+			translated++
+			if source.kind != kindParagraph {
+				return strings.Join(strings.Fields(unit.Target), " ")
+			}
+			return strings.TrimRight(unit.Target, "\r\n")
+		})
+
+		translation := base + "." + c.TargetLanguage + ".md"
+		if translated == 0 {
+			fmt.Printf("Skipped %s, nothing is translated\n", translation)
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(contentDir, filepath.FromSlash(translation)), []byte(document), 0644); err != nil {
+			return fmt.Errorf("cannot write %s + %+v", translation, err)
+		}
+		fmt.Printf("Wrote %s (%d of %d units translated)\n", translation, translated, len(units(segments)))
+		if outdated > 0 {
+			fmt.Printf("Warning %d unit(s) of %s were translated from an older version and were left in %s, export again to translate them\n", outdated, translation, c.SourceLanguage)
+		}
+	}
+	return nil
+}
+
+func unitID(n int) string {
+	return fmt.Sprintf("u%d", n+1)
+}
+
+func sameStructure(sources []segment, targets []segment) bool {
+	if len(sources) != len(targets) {
+		return false
+	}
+	for i := range sources {
+		if sources[i].kind != targets[i].kind {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
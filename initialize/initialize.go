@@ -1,58 +1,78 @@
 package initialize
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"workshop-builder/util"
+
+	"github.com/spf13/afero"
 )
 
-func InitCmd() {
+func InitCmd(ctx context.Context, configPath string) error {
+	fs := util.OsFs
+
+	configPath, err := util.FindConfig(configPath)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
 
-	fmt.Println("Generating default pace config.json")
-	if err := createDefaultConfig(); err != nil {
-		fmt.Println("Error " + err.Error())
-		return
+	fmt.Println("Generating default pace " + configPath)
+	if err := createDefaultConfig(fs, configPath); err != nil {
+		return err
 	}
 
 	fmt.Println("Generating default cf push manifest.yml")
-	if err := createDefaultManifest(); err != nil {
-		fmt.Println("Error " + err.Error())
-		return
+	if err := createDefaultManifest(fs); err != nil {
+		return err
 	}
 
 	fmt.Println("Generating default Staticfile.auth")
-	if err := createDefaultAuthFile(); err != nil {
-		fmt.Println("Error " + err.Error())
-		return
+	if err := createDefaultAuthFile(fs); err != nil {
+		return err
+	}
+
+	config, err := util.DetermineConfig(configPath)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+
+	lock, err := util.ReadLockFile(fs, util.LockFileName)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
 	}
 
 	fmt.Println("Pulling PACE workshop content...")
-	if err := getWorkshopContent(); err != nil {
-		fmt.Println("Error " + err.Error())
-		return
+	if err := getWorkshopContent(ctx, fs, config, lock); err != nil {
+		return util.WithExitCode(util.ExitSource, err)
 	}
 
 	fmt.Println("Sample Config, Manifest and Staticfile.auth have been generated. Edit the config, manifest and Staticfile.auth to your desire. Run `pace build` to build your first pace workshop!")
-	fmt.Println("Adjusting workshop content locally can be done within the paceWorkshopContent folder. Once your content is ready to be shared with your fellow team members, commit it back to pace workshop content! ")
+	fmt.Printf("Adjusting workshop content locally can be done within the %s folder. Once your content is ready to be shared with your fellow team members, commit it back to pace workshop content! \n", config.ContentDir)
+	return nil
 }
 
-func createDefaultConfig() error {
-	f, err := os.OpenFile("config.json", os.O_WRONLY|os.O_CREATE, 0666)
+func createDefaultConfig(fs afero.Fs, configPath string) error {
+	if _, err := fs.Stat(configPath); err == nil {
+		fmt.Printf("%s already exists, leaving it untouched\n", configPath)
+		return nil
+	}
+	f, err := fs.OpenFile(configPath, os.O_WRONLY|os.O_CREATE, 0666)
 	if err != nil {
-		return fmt.Errorf("error creating config.json")
+		return fmt.Errorf("error creating %s", configPath)
 	}
 	defer f.Close()
-	_, err = f.WriteString(util.DefaultConfig)
+	_, err = f.WriteString(util.DefaultConfigFor(configPath))
 	if err != nil {
-		return fmt.Errorf("error writing default config to config.json")
+		return fmt.Errorf("error writing default config to %s", configPath)
 	}
 
 	return nil
 }
 
-func createDefaultManifest() error {
-	f, err := os.OpenFile("manifest.yml", os.O_WRONLY|os.O_CREATE, 0666)
+func createDefaultManifest(fs afero.Fs) error {
+	f, err := fs.OpenFile("manifest.yml", os.O_WRONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return fmt.Errorf("error creating manifest.yml")
 	}
@@ -65,8 +85,8 @@ func createDefaultManifest() error {
 	return nil
 }
 
-func createDefaultAuthFile() error {
-	f, err := os.OpenFile("Staticfile.auth", os.O_WRONLY|os.O_CREATE, 0666)
+func createDefaultAuthFile(fs afero.Fs) error {
+	f, err := fs.OpenFile("Staticfile.auth", os.O_WRONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return fmt.Errorf("error creating Staticfile.auth")
 	}
@@ -79,12 +99,15 @@ func createDefaultAuthFile() error {
 	return nil
 }
 
-func getWorkshopContent() error {
+func getWorkshopContent(ctx context.Context, fs afero.Fs, config *util.WorkshopConfig, lock *util.LockFile) error {
 
-	if _, err := os.Stat("paceWorkshopContent"); os.IsNotExist(err) {
-		if err := util.CloneRepo("https://github.com/datastax-cda/workshop-content", "paceWorkshopContent"); err != nil {
+	if _, err := fs.Stat(config.ContentDir); os.IsNotExist(err) {
+		commit, err := util.FetchSource(ctx, os.Stdout, config.ContentSource.Location, config.ContentDir, lock.Content.PinnedRef(config.ContentSource))
+		if err != nil {
 			return err
 		}
+		lock.Content = util.NewLockedSource(config.ContentSource, commit)
+		return lock.Write(fs, util.LockFileName)
 	}
 	return nil
 }
@@ -1,48 +1,136 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"workshop-builder/build"
 	"workshop-builder/clean"
+	"workshop-builder/i18n"
 	"workshop-builder/initialize"
+	"workshop-builder/schema"
 	"workshop-builder/serve"
+	"workshop-builder/update"
+	"workshop-builder/util"
+	"workshop-builder/validate"
 	"workshop-builder/version"
 
 	"github.com/spf13/cobra"
 )
 
 func main() {
+	var configPath string
+	var buildFlags build.BuildFlags
 	var cmdBuild = &cobra.Command{
 		Use:   "build",
 		Short: "Build the DSCDA Workshop",
-		Long:  `build is for building a workshop based off the base DSCDA template, and the configuration provided.`,
-		Run: func(cmd *cobra.Command, args []string) {
-			build.BuildCmd()
+		Long:  `build is for building a workshop based off the base DSCDA template, and the configuration provided. The theme is kept in .dscda/theme so --clean can regenerate workshopGen/ without fetching it again. Pass --workdir and --output to build several workshops side by side, e.g. in CI. --dry-run prints the pages, assets, missing translations and menu weights the build would produce without writing anything.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			buildFlags.ConfigPath = configPath
+			return build.BuildCmd(cmd.Context(), buildFlags)
 		},
 	}
+	cmdBuild.Flags().BoolVar(&buildFlags.Clean, "clean", false, "regenerate workshopGen/ from the cached theme instead of reusing it")
+	cmdBuild.Flags().StringVar(&buildFlags.Output, "output", util.DefaultOutputDir, "folder the static site is written to")
+	cmdBuild.Flags().BoolVar(&buildFlags.DryRun, "dry-run", false, "print what the build would do without writing anything")
+	cmdBuild.Flags().StringVar(&buildFlags.Format, "format", build.FormatText, "output format of --dry-run: text or json")
+	cmdBuild.Flags().StringVar(&buildFlags.WorkDir, "workdir", "", "folder holding the workshop's sources and generated folders (default the working directory)")
+	cmdBuild.Flags().StringVar(&buildFlags.Environment, "env", "", "deployment environment whose settings override the config, e.g. staging (default $DSCDA_ENV)")
 	var cmdServe = &cobra.Command{
 		Use:   "serve",
 		Short: "Serve the DSCDA Workshop http://localhost:1313",
 		Long:  `serve uses Hugo to serve the content.  By default Hugo uses http://localhost:1313.`,
-		Run: func(cmd *cobra.Command, args []string) {
-			serve.ServeCmd()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve.ServeCmd(configPath)
 		},
 	}
 	var cmdInit = &cobra.Command{
 		Use:   "init",
 		Short: "Initialize a sample config.json, and manifest.yml",
-		Long:  `init bootstraps a configuration for dscda to build a workshop from, extend the config.json based on your needs. init also creates a basic cf manifest.yml for cf pushing.`,
-		Run: func(cmd *cobra.Command, args []string) {
-			initialize.InitCmd()
+		Long:  `init bootstraps a configuration for dscda to build a workshop from, extend the config.json based on your needs. Pass --config config.yaml or --config config.toml to start from a YAML or TOML config instead. init also creates a basic cf manifest.yml for cf pushing, dscda build routes a copy of it to workshopHostname in the genDir to deploy with cf push -f.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return initialize.InitCmd(cmd.Context(), configPath)
 		},
 	}
 	var cmdClean = &cobra.Command{
 		Use:   "clean",
 		Short: "Clean up all dscda-builder metadata and generated folders",
-		Long:  `Clean the workshop of all excess content that is not required for a dscda push. Technically this will delete both paceWorkshopContent/ and workshopGen/ folders, as well as all git metadata.`,
-		Run: func(cmd *cobra.Command, args []string) {
-			clean.CleanCmd()
+		Long:  `Clean the workshop of all excess content that is not required for a dscda push. Technically this will delete both the content and generated folders (paceWorkshopContent/ and workshopGen/ unless overridden by contentDir and genDir in config.json), as well as all git metadata.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return clean.CleanCmd(configPath)
+		},
+	}
+	var stash bool
+	var cmdUpdate = &cobra.Command{
+		Use:   "update",
+		Short: "Refresh the cached content and theme and rewrite dscda.lock",
+		Long:  `build pins the theme and content sources to the commits recorded in dscda.lock and reuses existing paceWorkshopContent/ and workshopGen/ folders. update resolves the configured refs again, fast-forwards the content checkout, refreshes the theme and records the new commits. Local modifications to the content are never overwritten: update refuses to run unless --stash is given, which saves them under dscda-stash/ first.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return update.UpdateCmd(cmd.Context(), configPath, stash)
+		},
+	}
+	cmdUpdate.Flags().BoolVar(&stash, "stash", false, "save local content modifications under dscda-stash/ and discard them before updating")
+	var cmdValidate = &cobra.Command{
+		Use:   "validate",
+		Short: "Check config.json for mistakes without building",
+		Long:  `validate reports every problem in config.json with its line and column: syntax errors, anything the schema printed by "dscda schema" rejects (unknown or missing keys, empty or unsupported modules, values of the wrong type), duplicate content entries and content files missing from the content checkout.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validate.ValidateCmd(configPath)
+		},
+	}
+	var cmdSchema = &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the workshop config",
+		Long:  `schema prints a JSON Schema (draft-07) describing config.json, generated from the same definitions validate checks against. Save it and reference it with "$schema" to get completion and inline documentation in your editor.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return schema.SchemaCmd()
 		},
 	}
+	var cmdI18n = &cobra.Command{
+		Use:   "i18n",
+		Short: "Inspect the translations of the workshop content",
+	}
+	var statusAll bool
+	var statusFormat string
+	var cmdI18nStatus = &cobra.Command{
+		Use:   "status",
+		Short: "Show which modules are translated in which languages",
+		Long:  `status prints a matrix of the workshop's modules and languages, flagging missing translations and translations last committed before their source in the default language. Pass --all to cover every translated file of the content checkout instead of the modules in the config, and --format json or --format markdown for tooling and pull request comments.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return i18n.StatusCmd(configPath, statusAll, statusFormat)
+		},
+	}
+	cmdI18nStatus.Flags().BoolVar(&statusAll, "all", false, "cover every translated file of the content checkout, not only the modules in the config")
+	cmdI18nStatus.Flags().StringVar(&statusFormat, "format", i18n.FormatTable, "output format: table, json or markdown")
+	cmdI18n.AddCommand(cmdI18nStatus)
+	var exportLanguages []string
+	var exportFormat, exportOutput string
+	var cmdI18nExport = &cobra.Command{
+		Use:   "export",
+		Short: "Export the text of the workshop's modules for translators as XLIFF or PO",
+		Long:  `export extracts the headings, paragraphs, list items and titles of the default language version of every module into one XLIFF 2.0 or gettext PO file per target language. Code blocks, HTML and shortcodes are left out. Translations that still match the structure of their source are filled in.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return i18n.ExportCmd(configPath, exportLanguages, exportFormat, exportOutput)
+		},
+	}
+	cmdI18nExport.Flags().StringSliceVar(&exportLanguages, "lang", nil, "languages to translate to, every workshop language but the default when omitted")
+	cmdI18nExport.Flags().StringVar(&exportFormat, "format", i18n.FormatXLIFF, "exchange format: xliff or po")
+	cmdI18nExport.Flags().StringVar(&exportOutput, "output", "translations", "folder the files are written to, one per language")
+	cmdI18n.AddCommand(cmdI18nExport)
+	var cmdI18nImport = &cobra.Command{
+		Use:   "import <file>...",
+		Short: "Write translated XLIFF or PO files back into the content",
+		Long:  `import writes <file>.<lang>.md into the content folder for every file of the given XLIFF 2.0 or PO files with translated units. The translations replace the text of the source version, keeping its Markdown structure and code blocks.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return i18n.ImportCmd(configPath, args)
+		},
+	}
+	cmdI18n.AddCommand(cmdI18nImport)
 	var cmdVersion = &cobra.Command{
 		Use:   "version",
 		Short: "dscda-builder version info",
@@ -51,11 +139,50 @@ func main() {
 			version.VersionCmd()
 		},
 	}
-	var rootCmd = &cobra.Command{Use: "dscda"}
+	// Ctrl-C or SIGTERM cancel the running command, which discards what it
+	// fetched or generated halfway. A second one exits right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		fmt.Fprintln(os.Stderr, "Interrupted, cleaning up... (press Ctrl-C again to quit now)")
+	}()
+	util.InstallGitTransport()
+	var timeout time.Duration
+	runCtx, cancelTimeout := ctx, context.CancelFunc(func() {})
+	var rootCmd = &cobra.Command{
+		Use: "dscda",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if timeout > 0 {
+				runCtx, cancelTimeout = context.WithTimeout(ctx, timeout)
+				cmd.SetContext(runCtx)
+			}
+		},
+	}
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "give up after this long, e.g. 10m, cleaning up like Ctrl-C does (default no limit)")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "workshop config file (config.json, config.yaml or config.toml); found in the working directory when omitted")
+	rootCmd.PersistentFlags().BoolVarP(&util.Verbose, "verbose", "v", false, "print diagnostic output, such as the credentials used for each git remote")
 	rootCmd.AddCommand(cmdBuild)
 	rootCmd.AddCommand(cmdServe)
 	rootCmd.AddCommand(cmdInit)
 	rootCmd.AddCommand(cmdClean)
+	rootCmd.AddCommand(cmdUpdate)
+	rootCmd.AddCommand(cmdValidate)
+	rootCmd.AddCommand(cmdSchema)
+	rootCmd.AddCommand(cmdI18n)
 	rootCmd.AddCommand(cmdVersion)
-	rootCmd.Execute()
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+	err := rootCmd.ExecuteContext(ctx)
+	canceled := runCtx.Err()
+	cancelTimeout()
+	if err != nil {
+		if canceled == context.DeadlineExceeded {
+			err = &util.ExitError{Code: util.ExitCanceled, Err: fmt.Errorf("timed out after %s + %+v", timeout, err)}
+		} else if canceled != nil {
+			err = &util.ExitError{Code: util.ExitCanceled, Err: fmt.Errorf("interrupted + %+v", err)}
+		}
+		fmt.Fprintln(os.Stderr, "Error "+err.Error())
+		os.Exit(util.ExitCode(err))
+	}
 }
@@ -0,0 +1,15 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+
+	"workshop-builder/util"
+)
+
+func SchemaCmd() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(util.ConfigSchema())
+}
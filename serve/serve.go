@@ -1,26 +1,36 @@
 package serve
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
 
+	"workshop-builder/util"
+
 	"github.com/gohugoio/hugo/commands"
 )
 
-func ServeCmd() {
+func ServeCmd(configPath string) error {
 
-	fmt.Println("Checking workshopGen")
-	if err := os.Chdir("workshopGen/"); err != nil {
-		fmt.Println("Please `build` before `serve` to create the content. Error:" + err.Error())
-		return
+	configPath, err := util.FindConfig(configPath)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+	config, err := util.DetermineConfig(configPath)
+	if errors.Is(err, util.ErrConfigNotFound) {
+		config = util.NewDefaultConfig()
+	} else if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
 	}
 
-	fmt.Println("Serving up a local version of your workshop!  Check your content at http://localhost:1313 ...")
-	if err := serveHugo(); err != nil {
-		fmt.Println("Error " + err.Error())
-		return
+	fmt.Println("Checking " + config.GenDir)
+	if err := os.Chdir(config.GenDir + "/"); err != nil {
+		return util.WithExitCode(util.ExitContent, fmt.Errorf("please `build` before `serve` to create the content + %+v", err))
 	}
+
+	fmt.Println("Serving up a local version of your workshop!  Check your content at http://localhost:1313 ...")
+	return serveHugo()
 }
 
 func serveHugo() error {
@@ -32,7 +42,7 @@ func serveHugo() error {
 			resp.Cmd.Println("")
 			resp.Cmd.Println(resp.Cmd.UsageString())
 		}
-		os.Exit(-1)
+		return util.WithExitCode(util.ExitHugo, fmt.Errorf("hugo failed to serve the site + %+v", resp.Err))
 	}
 	return nil
 }
@@ -0,0 +1,205 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"workshop-builder/util"
+)
+
+const stashDir = "dscda-stash"
+
+func UpdateCmd(ctx context.Context, configPath string, stash bool) error {
+	configPath, err := util.FindConfig(configPath)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+	config, err := util.DetermineConfig(configPath)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+
+	// The theme is refreshed by replacing GenDir.
+	if err := util.CheckOwnFolder(config.GenDir, "."); err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+
+	lock, err := util.ReadLockFile(util.OsFs, util.LockFileName)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+
+	fmt.Println("Updating content...")
+	if err := updateContent(ctx, config, lock, stash); err != nil {
+		return util.WithExitCode(util.ExitSource, err)
+	}
+
+	fmt.Println("Updating theme...")
+	if err := updateTheme(ctx, config, lock); err != nil {
+		return util.WithExitCode(util.ExitSource, err)
+	}
+
+	if err := lock.Write(util.OsFs, util.LockFileName); err != nil {
+		return err
+	}
+	fmt.Printf("%s updated. Run `dscda build` to rebuild the workshop.\n", util.LockFileName)
+	return nil
+}
+
+// updateTheme re-resolves the theme source and replaces the theme checkout in
+// GenDir when it moved. GenDir only holds the theme plus generated pages, so
+// it is refetched rather than fast-forwarded.
+func updateTheme(ctx context.Context, config *util.WorkshopConfig, lock *util.LockFile) error {
+	locked, err := resolveSource(ctx, config.ThemeSource, lock.Theme)
+	if err != nil {
+		return err
+	}
+	defer func() { lock.Theme = locked }()
+
+	if _, err := os.Stat(config.GenDir); os.IsNotExist(err) {
+		return nil
+	}
+	isLocal := util.IsLocalSource(config.ThemeSource.Location)
+	if !isLocal && lock.Theme.Matches(config.ThemeSource) && lock.Theme.Commit == locked.Commit {
+		return nil
+	}
+
+	fmt.Printf("Refreshing theme in %s...\n", config.GenDir)
+	if _, err := util.CacheTheme(ctx, os.Stdout, util.ThemeCacheDir, config.ThemeSource, locked); err != nil {
+		return err
+	}
+	staging := config.GenDir + ".update"
+	_ = os.RemoveAll(staging)
+	if err := util.CopyTheme(util.ThemeCacheDir, staging); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	if err := os.RemoveAll(config.GenDir); err != nil {
+		return err
+	}
+	return os.Rename(staging, config.GenDir)
+}
+
+// updateContent fast-forwards the content checkout to the configured ref and
+// reports the workshop modules touched upstream.
+func updateContent(ctx context.Context, config *util.WorkshopConfig, lock *util.LockFile, stash bool) error {
+	source := config.ContentSource
+	if _, err := os.Stat(config.ContentDir); os.IsNotExist(err) {
+		locked, err := resolveSource(ctx, source, lock.Content)
+		if err != nil {
+			return err
+		}
+		lock.Content = locked
+		return nil
+	}
+	if _, err := util.HeadCommit(config.ContentDir); util.IsLocalSource(source.Location) || err != nil {
+		fmt.Printf("%s is not a git checkout; remove it to pick up changes from %s\n", config.ContentDir, source.Location)
+		lock.Content = util.NewLockedSource(source, "")
+		return nil
+	}
+
+	modified, err := util.LocalModifications(config.ContentDir)
+	if err != nil {
+		return err
+	}
+	if len(modified) > 0 {
+		printModifications(modified)
+		if !stash {
+			return util.WithExitCode(util.ExitContent, fmt.Errorf("%s has local modifications or untracked files, commit them or re-run with --stash", config.ContentDir))
+		}
+		stashPath := filepath.Join(stashDir, time.Now().Format("20060102-150405"))
+		if err := util.StashModifications(config.ContentDir, modified, stashPath); err != nil {
+			return err
+		}
+		fmt.Printf("Local modifications saved to %s\n", stashPath)
+	}
+
+	from, to, err := util.FastForward(ctx, os.Stdout, config.ContentDir, source.Ref)
+	if err != nil {
+		return err
+	}
+	lock.Content = util.NewLockedSource(source, to)
+	if from == to {
+		fmt.Printf("%s is up to date at %s\n", config.ContentDir, to)
+		return nil
+	}
+
+	fmt.Printf("%s: %s -> %s\n", config.ContentDir, from, to)
+	files, err := util.ChangedFiles(config.ContentDir, from, to)
+	if err != nil {
+		return err
+	}
+	printChangedModules(config, files)
+	return nil
+}
+
+// resolveSource re-resolves the configured ref of source against its remote,
+// ignoring whatever commit was previously pinned.
+func resolveSource(ctx context.Context, source util.SourceConfig, previous *util.LockedSource) (*util.LockedSource, error) {
+	if util.IsLocalSource(source.Location) {
+		fmt.Printf("%s is a local source, nothing to pin\n", source.Location)
+		return util.NewLockedSource(source, ""), nil
+	}
+
+	commit, err := util.ResolveRemoteRef(ctx, os.Stdout, source.Location, source.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous.Matches(source) && previous.Commit == commit {
+		fmt.Printf("%s is up to date at %s\n", source.Location, commit)
+	} else if previous != nil && previous.Commit != "" {
+		fmt.Printf("%s: %s -> %s\n", source.Location, previous.Commit, commit)
+	} else {
+		fmt.Printf("%s pinned at %s\n", source.Location, commit)
+	}
+	return util.NewLockedSource(source, commit), nil
+}
+
+func printModifications(modified map[string]string) {
+	files := make([]string, 0, len(modified))
+	for file := range modified {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	fmt.Println("Local modifications:")
+	for _, file := range files {
+		fmt.Printf("  %s %s\n", modified[file], file)
+	}
+}
+
+// printChangedModules maps the changed files onto the modules of the config.
+// A content entry owns its own markdown files plus every other file in the
+// folder of its filename, since setWorkshopExtras copies the whole folder.
+func printChangedModules(config *util.WorkshopConfig, files []string) {
+	claimed := map[string]bool{}
+	fmt.Println("Modules changed upstream:")
+	for _, module := range config.Modules {
+		for _, content := range module.Content {
+			folder := path.Dir(content.Filename)
+			var changed int
+			for _, file := range files {
+				if strings.HasPrefix(file, content.Filename+".") || (path.Ext(file) != ".md" && path.Dir(file) == folder) {
+					changed++
+					claimed[file] = true
+				}
+			}
+			if changed > 0 {
+				fmt.Printf("  %s/%s (%d files)\n", module.Type, content.Name, changed)
+			}
+		}
+	}
+	if len(claimed) == 0 {
+		fmt.Println("  none")
+	}
+	if others := len(files) - len(claimed); others > 0 {
+		fmt.Printf("%d other files changed outside the modules of this workshop\n", others)
+	}
+}
@@ -0,0 +1,41 @@
+package update
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"workshop-builder/util"
+)
+
+func TestUpdateRefusesGenDirHoldingWorkshop(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "workshop")
+	if err := os.Mkdir(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	for _, genDir := range []string{".", ".."} {
+		config := `{"themeSource": {"location": "theme"}, "contentSource": {"location": "content"}, "genDir": "` + genDir + `",
+  "modules": [{"type": "demos", "content": [{"name": "example-demo", "filename": "example/example-demo"}]}]}`
+		if err := ioutil.WriteFile("config.json", []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := UpdateCmd(context.Background(), "", false)
+		if util.ExitCode(err) != util.ExitConfig {
+			t.Errorf("genDir %s: %v", genDir, err)
+		}
+		if _, err := os.Stat("config.json"); err != nil {
+			t.Fatalf("genDir %s removed the workshop: %v", genDir, err)
+		}
+	}
+}
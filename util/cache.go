@@ -0,0 +1,60 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	cp "github.com/otiai10/copy"
+)
+
+// CacheDir holds the state dscda keeps between builds, next to the config.
+const CacheDir = ".dscda"
+
+// ThemeCacheDir keeps a pristine checkout of the theme, so GenDir can be
+// regenerated without fetching the theme again.
+var ThemeCacheDir = filepath.Join(CacheDir, "theme")
+
+// CacheTheme makes sure cacheDir, usually ThemeCacheDir, holds the theme at
+// the commit pinned by locked and returns the resolved commit. A cached git checkout is reused as
+// long as it is still at the pinned commit; local directories and tarballs
+// are copied again, as that is cheap and picks up local edits. What fetching
+// prints goes to out.
+func CacheTheme(ctx context.Context, out io.Writer, cacheDir string, source SourceConfig, locked *LockedSource) (string, error) {
+	if !IsLocalSource(source.Location) && locked.Matches(source) && locked.Commit != "" {
+		if commit, err := HeadCommit(cacheDir); err == nil && commit == locked.Commit {
+			fmt.Fprintf(out, "Using cached theme at %s\n", commit)
+			return commit, nil
+		}
+	}
+	if err := os.RemoveAll(cacheDir); err != nil {
+		return "", err
+	}
+	return FetchSource(ctx, out, source.Location, cacheDir, locked.PinnedRef(source))
+}
+
+// CopyTheme copies the theme cached in cacheDir to destinationPath, leaving
+// out its git metadata. destinationPath only appears once the copy is
+// complete.
+func CopyTheme(cacheDir string, destinationPath string) error {
+	staging, err := ioutil.TempDir(filepath.Dir(filepath.Clean(destinationPath)), ".theme-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+	err = cp.Copy(cacheDir, staging, cp.Options{
+		Skip: func(info os.FileInfo, src, dest string) (bool, error) {
+			return info.Name() == ".git" || info.Name() == ".gitignore", nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot copy the theme to %s + %+v", destinationPath, err)
+	}
+	return os.Rename(staging, destinationPath)
+}
@@ -0,0 +1,246 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cp "github.com/otiai10/copy"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// LocalModifications lists the files of the git checkout at path that differ
+// from HEAD, mapped to their two letter status ("M ", " D", ...). Untracked
+// files are reported as "??", as moving the checkout deletes them; ignored
+// files are left out.
+func LocalModifications(path string) (map[string]string, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read status of %s + %+v", path, err)
+	}
+
+	modified := map[string]string{}
+	for file, fileStatus := range status {
+		if fileStatus.Staging == git.Untracked || fileStatus.Worktree == git.Untracked {
+			modified[file] = untrackedStatus
+			continue
+		}
+		if fileStatus.Staging == git.Unmodified && fileStatus.Worktree == git.Unmodified {
+			continue
+		}
+		modified[file] = string([]byte{byte(fileStatus.Staging), byte(fileStatus.Worktree)})
+	}
+	return modified, nil
+}
+
+// untrackedStatus is the status LocalModifications reports for a file git
+// does not track.
+const untrackedStatus = "??"
+
+// StashModifications copies the given files of the checkout at path into
+// stashPath and then resets the checkout to HEAD, discarding the changes.
+// Untracked files are moved into the stash before the reset, which would
+// delete them.
+func StashModifications(path string, files map[string]string, stashPath string) error {
+	for file := range files {
+		src := filepath.Join(path, file)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := cp.Copy(src, filepath.Join(stashPath, file)); err != nil {
+			return fmt.Errorf("cannot stash %s + %+v", file, err)
+		}
+	}
+	for file, status := range files {
+		if status != untrackedStatus {
+			continue
+		}
+		if err := os.Remove(filepath.Join(path, file)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot stash %s + %+v", file, err)
+		}
+	}
+	if modified, err := LocalModifications(path); err != nil {
+		return err
+	} else if untracked := untrackedFiles(modified); len(untracked) > 0 {
+		return fmt.Errorf("cannot reset %s, %s would be deleted", path, strings.Join(untracked, ", "))
+	}
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Reset(&git.ResetOptions{Mode: git.HardReset})
+}
+
+// FastForward fetches origin into the checkout at path and moves it to the
+// commit ref resolves to. An empty ref follows the upstream of the current
+// branch. The checkout is only moved when the new commit descends from the
+// current one, and refused while it has local modifications or untracked
+// files, which moving it would overwrite or delete. The previous and new
+// commits are returned, the fetch progress goes to out.
+func FastForward(ctx context.Context, out io.Writer, path string, ref string) (string, string, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return "", "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", "", err
+	}
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return "", "", err
+	}
+	auth, err := gitAuth(out, remote.Config().URLs[0])
+	if err != nil {
+		return "", "", err
+	}
+	if ref == "" {
+		if ref, err = upstreamBranch(remote, head, auth); err != nil {
+			return "", "", err
+		}
+	}
+	fmt.Fprintln(out, "git fetch "+path)
+	err = untilDone(ctx, func() error {
+		return repo.FetchContext(ctx, &git.FetchOptions{Auth: auth, Progress: out, Tags: git.AllTags})
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return "", "", fmt.Errorf("cannot fetch %s + %+v", path, err)
+	}
+
+	hash, err := resolveRef(repo, ref)
+	if err != nil {
+		return "", "", err
+	}
+	if hash == head.Hash() {
+		return head.Hash().String(), hash.String(), nil
+	}
+
+	current, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", "", err
+	}
+	target, err := repo.CommitObject(hash)
+	if err != nil {
+		return "", "", err
+	}
+	isAncestor, err := current.IsAncestor(target)
+	if err != nil {
+		return "", "", err
+	}
+	if !isAncestor {
+		return "", "", fmt.Errorf("cannot fast-forward %s from %s to %s, the histories have diverged", path, head.Hash(), hash)
+	}
+
+	modified, err := LocalModifications(path)
+	if err != nil {
+		return "", "", err
+	}
+	if len(modified) > 0 {
+		return "", "", fmt.Errorf("cannot move %s, it has local modifications or untracked files", path)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", "", err
+	}
+	if head.Name().IsBranch() {
+		err = worktree.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset})
+	} else {
+		err = worktree.Checkout(&git.CheckoutOptions{Hash: hash})
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("cannot move %s to %s + %+v", path, hash, err)
+	}
+	return head.Hash().String(), hash.String(), nil
+}
+
+// untrackedFiles lists the untracked files among modified, sorted.
+func untrackedFiles(modified map[string]string) []string {
+	var files []string
+	for file, status := range modified {
+		if status == untrackedStatus {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// upstreamBranch returns the branch to follow when no ref is configured: the
+// checked out branch, or the remote's default branch for a detached HEAD.
+func upstreamBranch(remote *git.Remote, head *plumbing.Reference, auth transport.AuthMethod) (string, error) {
+	if head.Name().IsBranch() {
+		return head.Name().Short(), nil
+	}
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", fmt.Errorf("cannot list remote refs + %+v", err)
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			return ref.Target().Short(), nil
+		}
+	}
+	return "", fmt.Errorf("cannot determine the default branch of %s, set a ref in config.json", git.DefaultRemoteName)
+}
+
+// ChangedFiles lists the files that differ between two commits of the git
+// repository at path.
+func ChangedFiles(path string, from string, to string) ([]string, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	fromTree, err := commitTree(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commitTree(repo, to)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, change := range changes {
+		if change.From.Name != "" {
+			files = append(files, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			files = append(files, change.To.Name)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func commitTree(repo *git.Repository, commit string) (*object.Tree, error) {
+	commitObject, err := repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, err
+	}
+	return commitObject.Tree()
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// commitFile writes file into the repository at dir and commits it.
+func commitFile(t *testing.T, dir string, file string, content string) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add(file); err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "dscda", Email: "dscda@example.com", When: time.Now()}
+	if _, err := worktree.Commit("change "+file, &git.CommitOptions{Author: signature}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cloneWithUpstreamChange returns a checkout of a repository that gained a
+// commit after it was cloned.
+func cloneWithUpstreamChange(t *testing.T) string {
+	isolateCredentials(t)
+	origin := t.TempDir()
+	if _, err := git.PlainInit(origin, false); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, origin, "demo.en.md", "# Demo\n")
+	checkout := filepath.Join(t.TempDir(), "content")
+	if _, err := git.PlainClone(checkout, false, &git.CloneOptions{URL: origin}); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, origin, "demo.en.md", "# Demo, updated\n")
+	return checkout
+}
+
+func TestFastForwardKeepsUntrackedFiles(t *testing.T) {
+	checkout := cloneWithUpstreamChange(t)
+	draft := filepath.Join(checkout, "draft.en.md")
+	if err := ioutil.WriteFile(draft, []byte("# Draft\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := LocalModifications(checkout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modified["draft.en.md"] != "??" {
+		t.Errorf("modifications = %v, want the untracked draft", modified)
+	}
+	if _, _, err := FastForward(context.Background(), ioutil.Discard, checkout, ""); err == nil {
+		t.Error("fast-forwarded a checkout with an untracked file")
+	}
+	if data, err := ioutil.ReadFile(draft); err != nil || string(data) != "# Draft\n" {
+		t.Errorf("draft = %q, %v", data, err)
+	}
+}
+
+func TestStashModificationsKeepsUntrackedFiles(t *testing.T) {
+	checkout := cloneWithUpstreamChange(t)
+	if err := ioutil.WriteFile(filepath.Join(checkout, "draft.en.md"), []byte("# Draft\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(checkout, "demo.en.md"), []byte("# Edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := LocalModifications(checkout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stash := filepath.Join(t.TempDir(), "stash")
+	if err := StashModifications(checkout, modified, stash); err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range map[string]string{"draft.en.md": "# Draft\n", "demo.en.md": "# Edited\n"} {
+		if data, err := ioutil.ReadFile(filepath.Join(stash, file)); err != nil || string(data) != content {
+			t.Errorf("stashed %s = %q, %v", file, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(checkout, "draft.en.md")); !os.IsNotExist(err) {
+		t.Errorf("draft left in the checkout, %v", err)
+	}
+
+	if _, _, err := FastForward(context.Background(), ioutil.Discard, checkout, ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(checkout, "demo.en.md")); string(data) != "# Demo, updated\n" {
+		t.Errorf("demo.en.md = %q after the fast-forward", data)
+	}
+}
@@ -0,0 +1,324 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+// Verbose enables diagnostic output such as the credential provider used for
+// each git remote.
+var Verbose bool
+
+const (
+	GitTokenEnv    = "DSCDA_GIT_TOKEN"
+	GitUsernameEnv = "DSCDA_GIT_USERNAME"
+)
+
+// CredentialProvider supplies credentials for git remotes. Auth returns a nil
+// AuthMethod when the provider has nothing for the given endpoint, so the
+// next provider in the chain is tried.
+type CredentialProvider interface {
+	Name() string
+	Auth(endpoint *transport.Endpoint) (transport.AuthMethod, error)
+}
+
+// credentialProviders is the chain consulted by ResolveAuth, in order.
+var credentialProviders = []CredentialProvider{
+	EnvCredentials{},
+	CredentialHelper{},
+	NetrcCredentials{},
+	SSHCredentials{},
+	AnonymousCredentials{},
+}
+
+// ResolveAuth walks the credential chain for url and returns the first auth
+// method found together with the name of the provider that supplied it.
+func ResolveAuth(url string) (transport.AuthMethod, string, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, provider := range credentialProviders {
+		auth, err := provider.Auth(endpoint)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s credentials for %s + %+v", provider.Name(), url, err)
+		}
+		if auth != nil {
+			return auth, provider.Name(), nil
+		}
+	}
+	return nil, AnonymousCredentials{}.Name(), nil
+}
+
+func gitAuth(out io.Writer, url string) (transport.AuthMethod, error) {
+	auth, provider, err := ResolveAuth(url)
+	if err != nil {
+		return nil, err
+	}
+	if Verbose {
+		fmt.Fprintf(out, "Using %s credentials for %s\n", provider, url)
+	}
+	return auth, nil
+}
+
+func isHTTP(endpoint *transport.Endpoint) bool {
+	return endpoint.Protocol == "http" || endpoint.Protocol == "https"
+}
+
+// hostPort renders the endpoint host the way git and .netrc name it, with
+// the port only when it is not the protocol default.
+func hostPort(endpoint *transport.Endpoint) string {
+	if endpoint.Port == 0 || (endpoint.Protocol == "https" && endpoint.Port == 443) || (endpoint.Protocol == "http" && endpoint.Port == 80) {
+		return endpoint.Host
+	}
+	return endpoint.Host + ":" + strconv.Itoa(endpoint.Port)
+}
+
+// EnvCredentials uses a personal access token from DSCDA_GIT_TOKEN for HTTP
+// remotes. DSCDA_GIT_USERNAME overrides the username, which most git hosts
+// ignore for token authentication.
+type EnvCredentials struct{}
+
+func (EnvCredentials) Name() string { return "environment" }
+
+func (EnvCredentials) Auth(endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	token := strings.TrimSpace(os.Getenv(GitTokenEnv))
+	if token == "" || !isHTTP(endpoint) {
+		return nil, nil
+	}
+	username := os.Getenv(GitUsernameEnv)
+	if username == "" {
+		username = "dscda"
+	}
+	return &http.BasicAuth{Username: username, Password: token}, nil
+}
+
+// CredentialHelper asks the configured git credential helpers through
+// `git credential fill`. Terminal prompts are disabled, so a missing helper
+// or git binary simply yields no credentials.
+type CredentialHelper struct {
+	// Command overrides the command speaking the credential helper
+	// protocol, `git credential fill` by default.
+	Command []string
+}
+
+func (CredentialHelper) Name() string { return "git credential helper" }
+
+func (helper CredentialHelper) Auth(endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	if !isHTTP(endpoint) {
+		return nil, nil
+	}
+	command := helper.Command
+	if len(command) == 0 {
+		command = []string{"git", "credential", "fill"}
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var input bytes.Buffer
+	fmt.Fprintf(&input, "protocol=%s\nhost=%s\n", endpoint.Protocol, hostPort(endpoint))
+	if path := strings.TrimPrefix(endpoint.Path, "/"); path != "" {
+		fmt.Fprintf(&input, "path=%s\n", path)
+	}
+	input.WriteString("\n")
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = &input
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil
+	}
+
+	var username, password string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	if password == "" {
+		return nil, nil
+	}
+	return &http.BasicAuth{Username: username, Password: password}, nil
+}
+
+// NetrcCredentials reads the login for the remote host from the file named
+// by $NETRC, or ~/.netrc (~/_netrc on Windows).
+type NetrcCredentials struct{}
+
+func (NetrcCredentials) Name() string { return "netrc" }
+
+func (NetrcCredentials) Auth(endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	if !isHTTP(endpoint) {
+		return nil, nil
+	}
+	path := netrcPath()
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	login, password, found := parseNetrc(string(data), endpoint.Host)
+	if !found {
+		return nil, nil
+	}
+	return &http.BasicAuth{Username: login, Password: password}, nil
+}
+
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{".netrc", "_netrc"} {
+		path := filepath.Join(home, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// parseNetrc returns the login and password of the machine entry for host,
+// falling back to the default entry.
+func parseNetrc(data string, host string) (string, string, bool) {
+	type entry struct {
+		login, password string
+	}
+	var (
+		machines   = map[string]*entry{}
+		defaults   *entry
+		current    *entry
+		fields     = strings.Fields(data)
+		inMacroDef bool
+	)
+	for i := 0; i < len(fields); i++ {
+		if inMacroDef {
+			// macdef bodies run until an empty line, which strings.Fields
+			// cannot see; the next machine or default keyword ends it instead.
+			if fields[i] != "machine" && fields[i] != "default" {
+				continue
+			}
+			inMacroDef = false
+		}
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				current = &entry{}
+				if _, seen := machines[fields[i]]; !seen {
+					machines[fields[i]] = current
+				}
+			}
+		case "default":
+			current = &entry{}
+			defaults = current
+		case "login":
+			if current != nil && i+1 < len(fields) {
+				i++
+				current.login = fields[i]
+			}
+		case "password":
+			if current != nil && i+1 < len(fields) {
+				i++
+				current.password = fields[i]
+			}
+		case "macdef":
+			inMacroDef = true
+		}
+	}
+	if machine, ok := machines[host]; ok {
+		return machine.login, machine.password, true
+	}
+	if defaults != nil {
+		return defaults.login, defaults.password, true
+	}
+	return "", "", false
+}
+
+// SSHCredentials authenticates ssh remotes (git@host:path or ssh://) through
+// a running ssh-agent, or else the first unencrypted default key in ~/.ssh.
+type SSHCredentials struct{}
+
+func (SSHCredentials) Name() string { return "ssh" }
+
+func (SSHCredentials) Auth(endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	if endpoint.Protocol != "ssh" {
+		return nil, nil
+	}
+	user := endpoint.User
+	if user == "" {
+		user = ssh.DefaultUsername
+	}
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		if auth, err := ssh.NewSSHAgentAuth(user); err == nil {
+			return auth, nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+	for _, key := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		path := filepath.Join(home, ".ssh", key)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if auth, err := ssh.NewPublicKeysFromFile(user, path, ""); err == nil {
+			return auth, nil
+		}
+	}
+	return nil, nil
+}
+
+// AnonymousCredentials ends the chain: public repositories and local paths
+// need no credentials at all.
+type AnonymousCredentials struct{}
+
+func (AnonymousCredentials) Name() string { return "anonymous" }
+
+func (AnonymousCredentials) Auth(endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	return nil, nil
+}
+
+// cut is strings.Cut, which is not available in go 1.17.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
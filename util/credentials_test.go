@@ -0,0 +1,276 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+)
+
+// isolateCredentials points HOME and NETRC at an empty directory and clears
+// the token variables, so the developer's own credentials never leak in.
+func isolateCredentials(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("NETRC", "")
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv(GitTokenEnv, "")
+	t.Setenv(GitUsernameEnv, "")
+	return home
+}
+
+func basicAuth(t *testing.T, auth transport.AuthMethod) *githttp.BasicAuth {
+	basic, ok := auth.(*githttp.BasicAuth)
+	if !ok {
+		t.Fatalf("expected basic auth, got %T", auth)
+	}
+	return basic
+}
+
+func TestResolveAuthEnvironment(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv(GitTokenEnv, "s3cret\n")
+
+	auth, provider, err := ResolveAuth("https://github.com/datastax-cda/workshop-content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider != "environment" {
+		t.Errorf("provider = %q, want environment", provider)
+	}
+	if basic := basicAuth(t, auth); basic.Password != "s3cret" || basic.Username != "dscda" {
+		t.Errorf("auth = %s:%s", basic.Username, basic.Password)
+	}
+
+	t.Setenv(GitUsernameEnv, "builder")
+	auth, _, _ = ResolveAuth("https://github.com/datastax-cda/workshop-content")
+	if basic := basicAuth(t, auth); basic.Username != "builder" {
+		t.Errorf("username = %q, want builder", basic.Username)
+	}
+}
+
+func TestResolveAuthNetrc(t *testing.T) {
+	home := isolateCredentials(t)
+	netrc := `machine example.com login other password wrong
+machine git.internal.example
+    login mirror
+    password from-netrc
+default login anyone password from-default
+`
+	if err := ioutil.WriteFile(filepath.Join(home, ".netrc"), []byte(netrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, provider, err := ResolveAuth("https://git.internal.example/workshops/content.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider != "netrc" {
+		t.Errorf("provider = %q, want netrc", provider)
+	}
+	if basic := basicAuth(t, auth); basic.Username != "mirror" || basic.Password != "from-netrc" {
+		t.Errorf("auth = %s:%s", basic.Username, basic.Password)
+	}
+
+	auth, _, _ = ResolveAuth("https://unknown.example/content.git")
+	if basic := basicAuth(t, auth); basic.Password != "from-default" {
+		t.Errorf("default password = %q", basic.Password)
+	}
+}
+
+func TestCredentialHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper stand-in is a shell script")
+	}
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "helper")
+	script := `#!/bin/sh
+input=$(cat)
+case "$input" in
+*host=git.internal.example*) printf 'protocol=https\nhost=git.internal.example\nusername=helper-user\npassword=helper-pass\n' ;;
+*) exit 1 ;;
+esac
+`
+	if err := ioutil.WriteFile(helper, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := CredentialHelper{Command: []string{helper}}
+	endpoint, _ := transport.NewEndpoint("https://git.internal.example/content.git")
+	auth, err := provider.Auth(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basic := basicAuth(t, auth); basic.Username != "helper-user" || basic.Password != "helper-pass" {
+		t.Errorf("auth = %s:%s", basic.Username, basic.Password)
+	}
+
+	endpoint, _ = transport.NewEndpoint("https://elsewhere.example/content.git")
+	if auth, err := provider.Auth(endpoint); auth != nil || err != nil {
+		t.Errorf("expected no credentials, got %v, %v", auth, err)
+	}
+}
+
+func TestResolveAuthAnonymous(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("PATH", "")
+
+	for _, url := range []string{"https://github.com/datastax-cda/workshop-base", "file:///tmp/content"} {
+		auth, provider, err := ResolveAuth(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth != nil || provider != "anonymous" {
+			t.Errorf("%s: got %v from %q, want anonymous", url, auth, provider)
+		}
+	}
+}
+
+func TestCloneRepoWithResolvedCredentials(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("PATH", "")
+
+	commit, url := serveGitRepo(t, "dscda", "s3cret")
+
+	if _, err := CloneRepo(context.Background(), ioutil.Discard, url, filepath.Join(t.TempDir(), "anonymous"), ""); err == nil {
+		t.Fatal("anonymous clone of a protected repository succeeded")
+	}
+
+	t.Setenv(GitTokenEnv, "s3cret")
+	dest := filepath.Join(t.TempDir(), "content")
+	resolved, err := CloneRepo(context.Background(), ioutil.Discard, url, dest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != commit {
+		t.Errorf("resolved commit %s, want %s", resolved, commit)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dest, "example", "example-demo.en.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Example") {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+func TestResolveRemoteRef(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("PATH", "")
+	t.Setenv(GitTokenEnv, "s3cret")
+
+	commit, url := serveGitRepo(t, "dscda", "s3cret")
+	for _, ref := range []string{"", "master", commit} {
+		resolved, err := ResolveRemoteRef(context.Background(), ioutil.Discard, url, ref)
+		if err != nil {
+			t.Fatalf("%q: %v", ref, err)
+		}
+		if resolved != commit {
+			t.Errorf("%q resolved to %s, want %s", ref, resolved, commit)
+		}
+	}
+	if _, err := ResolveRemoteRef(context.Background(), ioutil.Discard, url, "missing"); err == nil {
+		t.Error("resolved a ref the remote does not have")
+	}
+}
+
+// serveGitRepo creates a one commit repository and serves it over the git
+// smart HTTP protocol behind basic auth, standing in for a git host.
+func serveGitRepo(t *testing.T, username, password string) (string, string) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "example"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "example", "example-demo.en.md"), []byte("# Example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("example/example-demo.en.md"); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := worktree.Commit("initial content", &git.CommitOptions{
+		Author: &object.Signature{Name: "dscda", Email: "dscda@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitServer := server.NewServer(singleRepoLoader{repo.Storer})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		endpoint, _ := transport.NewEndpoint("http://stand-in/repo")
+		session, err := gitServer.NewUploadPackSession(endpoint, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer session.Close()
+
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/info/refs"):
+			refs, err := session.AdvertisedReferences()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			refs.Prefix = [][]byte{[]byte("# service=git-upload-pack"), pktline.Flush}
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+			_ = refs.Encode(w)
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/git-upload-pack"):
+			request := packp.NewUploadPackRequest()
+			if err := request.Decode(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			response, err := session.UploadPack(r.Context(), request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+			_ = response.Encode(w)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(httpServer.Close)
+	return hash.String(), httpServer.URL + "/repo"
+}
+
+type singleRepoLoader struct {
+	storer storer.Storer
+}
+
+func (loader singleRepoLoader) Load(*transport.Endpoint) (storer.Storer, error) {
+	return loader.storer, nil
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes of dscda, one per class of failure, so scripts and CI can tell
+// a broken config from an unreachable source or a Hugo error.
+const (
+	ExitFailure = 1
+	ExitConfig  = 2
+	ExitSource  = 3
+	ExitContent = 4
+	ExitHugo    = 5
+	// ExitCanceled is used when the command was interrupted or ran out of
+	// time.
+	ExitCanceled = 6
+)
+
+// ExitError is an error that makes dscda exit with Code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// WithExitCode classifies err, keeping the class it already has. A nil err
+// stays nil.
+func WithExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitError *ExitError
+	if errors.As(err, &exitError) {
+		return err
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// ExitCode returns the exit code err calls for, ExitFailure for errors that
+// were not classified.
+func ExitCode(err error) int {
+	var exitError *ExitError
+	if errors.As(err, &exitError) {
+		return exitError.Code
+	}
+	return ExitFailure
+}
+
+// Errors collects problems found in one pass, so they are all reported at
+// once instead of stopping at the first.
+type Errors []error
+
+func (errs Errors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	message := fmt.Sprintf("%d problems found:", len(errs))
+	for _, err := range errs {
+		message += "\n  " + err.Error()
+	}
+	return message
+}
+
+// Add appends err, flattening collected errors. A nil err is ignored.
+func (errs *Errors) Add(err error) {
+	if nested, ok := err.(Errors); ok {
+		*errs = append(*errs, nested...)
+	} else if err != nil {
+		*errs = append(*errs, err)
+	}
+}
+
+// Err returns the collected errors, or nil when there are none.
+func (errs Errors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,273 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileNames are the configs looked for in the working directory when
+// no --config is given.
+var ConfigFileNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// FindConfig returns configPath when set. Otherwise it picks the one config
+// file of ConfigFileNames present in the working directory, defaulting to
+// config.json when there is none.
+func FindConfig(configPath string) (string, error) {
+	return FindConfigIn("", configPath)
+}
+
+// FindConfigIn is FindConfig for the workshop in dir.
+func FindConfigIn(dir string, configPath string) (string, error) {
+	if configPath != "" {
+		return configPath, nil
+	}
+	var found []string
+	for _, name := range ConfigFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, filepath.Join(dir, name))
+		}
+	}
+	switch len(found) {
+	case 0:
+		return filepath.Join(dir, ConfigFileNames[0]), nil
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("found %s, pick one with --config", strings.Join(found, " and "))
+}
+
+// parseConfigNodes parses a JSON, YAML or TOML config, chosen by file
+// extension, into a configNode tree.
+func parseConfigNodes(file string, data []byte) (*configNode, ConfigErrors) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return parseYAMLConfig(file, data)
+	case ".toml":
+		return parseTOMLConfig(file, data)
+	}
+	return parseJSONConfig(file, data)
+}
+
+// interfaceValue converts the node back into plain maps, slices and scalars,
+// ready to be marshaled as JSON whatever format it was read from.
+func (node *configNode) interfaceValue() interface{} {
+	switch node.kind {
+	case objectNode:
+		object := make(map[string]interface{}, len(node.fields))
+		for _, field := range node.fields {
+			object[field.name] = field.value.interfaceValue()
+		}
+		return object
+	case arrayNode:
+		array := make([]interface{}, len(node.items))
+		for i, item := range node.items {
+			array[i] = item.interfaceValue()
+		}
+		return array
+	}
+	return node.value
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+func parseYAMLConfig(file string, data []byte) (*configNode, ConfigErrors) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		configErr := ConfigError{File: file, Message: "syntax error: " + strings.TrimPrefix(err.Error(), "yaml: ")}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			configErr.Line, _ = strconv.Atoi(match[1])
+			configErr.Column = 1
+		}
+		return nil, ConfigErrors{configErr}
+	}
+	if document.Kind == 0 || len(document.Content) == 0 {
+		return &configNode{position: position{Line: 1, Column: 1}, kind: objectNode}, nil
+	}
+	return yamlNode(document.Content[0]), nil
+}
+
+func yamlNode(node *yaml.Node) *configNode {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	converted := &configNode{position: position{Line: node.Line, Column: node.Column}}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		converted.kind = objectNode
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			converted.fields = append(converted.fields, configField{
+				position: position{Line: key.Line, Column: key.Column},
+				name:     key.Value,
+				value:    yamlNode(node.Content[i+1]),
+			})
+		}
+	case yaml.SequenceNode:
+		converted.kind = arrayNode
+		for _, item := range node.Content {
+			converted.items = append(converted.items, yamlNode(item))
+		}
+	case yaml.ScalarNode:
+		var value interface{}
+		_ = node.Decode(&value)
+		switch value := value.(type) {
+		case nil:
+			converted.kind = nullNode
+		case bool:
+			converted.kind, converted.value = boolNode, value
+		case int:
+			converted.kind, converted.value = numberNode, json.Number(strconv.Itoa(value))
+		case float64:
+			converted.kind, converted.value = numberNode, json.Number(strconv.FormatFloat(value, 'g', -1, 64))
+		default:
+			converted.kind, converted.value = stringNode, node.Value
+		}
+	}
+	return converted
+}
+
+func parseTOMLConfig(file string, data []byte) (*configNode, ConfigErrors) {
+	// The strict decoder reports syntax errors, duplicate keys and
+	// redefined tables with their position; the AST walk below can then
+	// assume a well formed document.
+	var generic map[string]interface{}
+	if err := toml.Unmarshal(data, &generic); err != nil {
+		configErr := ConfigError{File: file, Message: "syntax error: " + err.Error()}
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			configErr.Line, configErr.Column = decodeErr.Position()
+		}
+		return nil, ConfigErrors{configErr}
+	}
+
+	builder := tomlBuilder{data: data}
+	root := &configNode{position: position{Line: 1, Column: 1}, kind: objectNode}
+	current := root
+
+	parser := unstable.Parser{}
+	parser.Reset(data)
+	for parser.NextExpression() {
+		expression := parser.Expression()
+		switch expression.Kind {
+		case unstable.KeyValue:
+			builder.setKeyValue(current, expression)
+		case unstable.Table:
+			keys := builder.keys(expression.Key())
+			current = builder.descend(root, keys)
+		case unstable.ArrayTable:
+			keys := builder.keys(expression.Key())
+			parent := builder.descend(root, keys[:len(keys)-1])
+			last := keys[len(keys)-1]
+			array := builder.child(parent, last, arrayNode)
+			current = &configNode{position: last.position, kind: objectNode}
+			array.items = append(array.items, current)
+		}
+	}
+	return root, nil
+}
+
+type tomlBuilder struct {
+	data []byte
+}
+
+type tomlKey struct {
+	position
+	name string
+}
+
+func (builder tomlBuilder) position(node *unstable.Node, fallback position) position {
+	if node.Raw.Length == 0 {
+		return fallback
+	}
+	return offsetPosition(builder.data, int(node.Raw.Offset))
+}
+
+func (builder tomlBuilder) keys(iterator unstable.Iterator) []tomlKey {
+	var keys []tomlKey
+	for iterator.Next() {
+		node := iterator.Node()
+		keys = append(keys, tomlKey{position: builder.position(node, position{}), name: string(node.Data)})
+	}
+	return keys
+}
+
+// child returns the field named by key in parent, creating it with the
+// given kind when missing. For arrays of tables the last element is used,
+// as TOML's [a.b] after [[a]] refers to it.
+func (builder tomlBuilder) child(parent *configNode, key tomlKey, kind nodeKind) *configNode {
+	if existing := parent.field(key.name); existing != nil {
+		if existing.kind == arrayNode && kind == objectNode && len(existing.items) > 0 {
+			return existing.items[len(existing.items)-1]
+		}
+		return existing
+	}
+	node := &configNode{position: key.position, kind: kind}
+	parent.fields = append(parent.fields, configField{position: key.position, name: key.name, value: node})
+	return node
+}
+
+func (builder tomlBuilder) descend(node *configNode, keys []tomlKey) *configNode {
+	for _, key := range keys {
+		node = builder.child(node, key, objectNode)
+	}
+	return node
+}
+
+func (builder tomlBuilder) setKeyValue(table *configNode, expression *unstable.Node) {
+	keys := builder.keys(expression.Key())
+	parent := builder.descend(table, keys[:len(keys)-1])
+	last := keys[len(keys)-1]
+	value := builder.value(expression.Value(), last.position)
+	parent.fields = append(parent.fields, configField{position: last.position, name: last.name, value: value})
+}
+
+func (builder tomlBuilder) value(node *unstable.Node, fallback position) *configNode {
+	converted := &configNode{position: builder.position(node, fallback)}
+	data := string(node.Data)
+
+	switch node.Kind {
+	case unstable.InlineTable:
+		converted.kind = objectNode
+		children := node.Children()
+		for children.Next() {
+			builder.setKeyValue(converted, children.Node())
+		}
+		if len(converted.fields) > 0 {
+			converted.position = converted.fields[0].position
+		}
+	case unstable.Array:
+		converted.kind = arrayNode
+		children := node.Children()
+		for children.Next() {
+			converted.items = append(converted.items, builder.value(children.Node(), converted.position))
+		}
+	case unstable.Bool:
+		converted.kind, converted.value = boolNode, data == "true"
+	case unstable.Integer:
+		if value, err := strconv.ParseInt(strings.ReplaceAll(data, "_", ""), 0, 64); err == nil {
+			converted.kind, converted.value = numberNode, json.Number(strconv.FormatInt(value, 10))
+		} else {
+			converted.kind, converted.value = stringNode, data
+		}
+	case unstable.Float:
+		if value, err := strconv.ParseFloat(strings.ReplaceAll(data, "_", ""), 64); err == nil {
+			converted.kind, converted.value = numberNode, json.Number(strconv.FormatFloat(value, 'g', -1, 64))
+		} else {
+			converted.kind, converted.value = stringNode, data
+		}
+	default:
+		converted.kind, converted.value = stringNode, data
+	}
+	return converted
+}
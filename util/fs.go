@@ -0,0 +1,100 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// OsFs is the disk. Builds, init and clean take an afero.Fs so they can run
+// against an in-memory filesystem in tests.
+var OsFs afero.Fs = afero.NewOsFs()
+
+// IsOsFs reports whether fs is the disk, which fetching sources and running
+// Hugo need.
+func IsOsFs(fs afero.Fs) bool {
+	_, ok := fs.(*afero.OsFs)
+	return ok
+}
+
+// CopyFile copies the file source to destination on fs, creating the folders
+// it needs.
+func CopyFile(fs afero.Fs, source string, destination string) error {
+	info, err := fs.Stat(source)
+	if err != nil {
+		return err
+	}
+	if err := fs.MkdirAll(filepath.Dir(destination), os.FileMode(0755)); err != nil {
+		return err
+	}
+	in, err := fs.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := fs.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// CopyDir copies the folder source to destination on fs. copied, when not
+// nil, is called for every file written.
+func CopyDir(fs afero.Fs, source string, destination string, copied func(source string, destination string, info os.FileInfo)) error {
+	return afero.Walk(fs, source, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, relative)
+		if info.IsDir() {
+			return fs.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if err := CopyFile(fs, file, target); err != nil {
+			return err
+		}
+		if copied != nil {
+			copied(file, target, info)
+		}
+		return nil
+	})
+}
+
+// Within reports whether path is dir or lies inside it.
+func Within(dir string, path string) bool {
+	relative, err := filepath.Rel(dir, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// CheckOwnFolder refuses a folder dscda removes or replaces, such as GenDir
+// or the output, when it is or holds workDir, where the config and sources
+// of the workshop are kept, or the home folder.
+func CheckOwnFolder(dir string, workDir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	workDir, err = filepath.Abs(workDir)
+	if err != nil {
+		return err
+	}
+	if Within(dir, workDir) {
+		return fmt.Errorf("refusing to replace %s, it holds the workshop in %s", dir, workDir)
+	}
+	if home, err := os.UserHomeDir(); err == nil && Within(dir, home) {
+		return fmt.Errorf("refusing to replace %s, it holds the home folder %s", dir, home)
+	}
+	return nil
+}
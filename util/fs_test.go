@@ -0,0 +1,21 @@
+package util
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckOwnFolder(t *testing.T) {
+	workDir := t.TempDir()
+	for dir, refused := range map[string]bool{
+		workDir:                             true,
+		filepath.Dir(workDir):               true,
+		"/":                                 true,
+		filepath.Join(workDir, "publicGen"): false,
+		filepath.Join(workDir, "..", filepath.Base(workDir)+"-site"): false,
+	} {
+		if err := CheckOwnFolder(dir, workDir); (err != nil) != refused {
+			t.Errorf("CheckOwnFolder(%s) = %v", dir, err)
+		}
+	}
+}
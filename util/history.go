@@ -0,0 +1,80 @@
+package util
+
+import (
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// LastCommitTimes returns when each of files, relative to the repository at
+// path, was last changed by a commit reachable from HEAD. Changes merged in
+// from other branches count from the merge commit. Files that are not in the
+// HEAD commit are left out, so the history is only walked until every other
+// file was found.
+func LastCommitTimes(path string, files []string) (map[string]time.Time, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, file := range files {
+		if _, err := headTree.FindEntry(file); err == nil {
+			wanted[file] = true
+		}
+	}
+
+	commits, err := repo.Log(&git.LogOptions{From: head.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer commits.Close()
+	times := map[string]time.Time{}
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if len(times) == len(wanted) {
+			return storer.ErrStop
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return err
+		}
+		var parentTree *object.Tree
+		if commit.NumParents() > 0 {
+			parent, err := commit.Parent(0)
+			if err != nil {
+				return err
+			}
+			if parentTree, err = parent.Tree(); err != nil {
+				return err
+			}
+		}
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			name := change.To.Name
+			if _, seen := times[name]; wanted[name] && !seen {
+				times[name] = commit.Committer.When
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return times, nil
+}
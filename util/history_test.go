@@ -0,0 +1,55 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestLastCommitTimes(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day, file := range []string{"demo.en.md", "demo.es.md", "demo.en.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(fmt.Sprintf("version %d\n", day)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add(file); err != nil {
+			t.Fatal(err)
+		}
+		signature := &object.Signature{Name: "dscda", Email: "dscda@example.com", When: start.AddDate(0, 0, day)}
+		if _, err := worktree.Commit("change "+file, &git.CommitOptions{Author: signature, Committer: signature}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Uncommitted files have no commit time.
+	if err := ioutil.WriteFile(filepath.Join(dir, "demo.fr.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	times, err := LastCommitTimes(dir, []string{"demo.en.md", "demo.es.md", "demo.fr.md", "demo.de.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Time{"demo.en.md": start.AddDate(0, 0, 2), "demo.es.md": start.AddDate(0, 0, 1)}
+	if len(times) != len(want) {
+		t.Errorf("times = %v, want %v", times, want)
+	}
+	for file, when := range want {
+		if !times[file].Equal(when) {
+			t.Errorf("%s last changed %v, want %v", file, times[file], when)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultLanguage is the language workshops are written in first, and the
+// one built when no translations are found.
+const DefaultLanguage = "en"
+
+// LanguageNames are the names shown in the language switcher. Languages not
+// listed here are shown by their code.
+var LanguageNames = map[string]string{
+	"de": "Deutsch",
+	"en": "English",
+	"es": "Español",
+	"fr": "Français",
+	"it": "Italiano",
+	"ja": "日本語",
+	"ko": "한국어",
+	"nl": "Nederlands",
+	"pl": "Polski",
+	"pt": "Português",
+	"zh": "中文",
+}
+
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]+)?$`)
+
+// LanguageName returns the name of the language shown in the language
+// switcher.
+func LanguageName(code string) string {
+	if name, ok := LanguageNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// SplitLanguage splits a translated content file name such as
+// example/example-demo.es.md into its base, example/example-demo, and its
+// language, es.
+func SplitLanguage(file string) (string, string, bool) {
+	if !strings.HasSuffix(file, ".md") {
+		return "", "", false
+	}
+	name := strings.TrimSuffix(file, ".md")
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 || !languageCode.MatchString(name[dot+1:]) {
+		return "", "", false
+	}
+	return name[:dot], name[dot+1:], true
+}
+
+// WorkshopLanguages returns the languages the workshop is built in, the
+// default language first. These are the configured languages or, when none
+// are configured, the languages the modules are translated in, discovered
+// from their <filename>.<lang>.md files in contentDir.
+func WorkshopLanguages(config *WorkshopConfig) []string {
+	if len(config.Languages) > 0 {
+		var languages []string
+		for _, language := range config.Languages {
+			if !contains(languages, language) {
+				languages = append(languages, language)
+			}
+		}
+		return languages
+	}
+
+	found := map[string]bool{}
+	for _, module := range config.Modules {
+		for _, content := range module.Content {
+			if content.Filename == "" {
+				continue
+			}
+			base := filepath.Join(config.ContentDir, filepath.FromSlash(content.Filename))
+			matches, _ := filepath.Glob(base + ".*.md")
+			for _, match := range matches {
+				if matchBase, language, ok := SplitLanguage(match); ok && matchBase == base {
+					found[language] = true
+				}
+			}
+		}
+	}
+
+	return SortLanguages(found)
+}
+
+// SortLanguages orders a set of languages for display, the default language
+// first, which is always included, and the others alphabetically.
+func SortLanguages(found map[string]bool) []string {
+	languages := []string{DefaultLanguage}
+	var others []string
+	for language := range found {
+		if language != DefaultLanguage {
+			others = append(others, language)
+		}
+	}
+	sort.Strings(others)
+	return append(languages, others...)
+}
+
+// untranslatedNotices tell readers a page is shown in the default language,
+// in the language they picked. %s is the name of the default language.
+var untranslatedNotices = map[string]string{
+	"de": "Diese Seite wurde noch nicht übersetzt und wird auf %s angezeigt.",
+	"en": "This page has not been translated yet and is shown in %s.",
+	"es": "Esta página aún no está traducida y se muestra en %s.",
+	"fr": "Cette page n'est pas encore traduite et s'affiche en %s.",
+	"it": "Questa pagina non è ancora stata tradotta ed è mostrata in %s.",
+	"ja": "このページはまだ翻訳されていないため、%sで表示しています。",
+	"pt": "Esta página ainda não foi traduzida e é exibida em %s.",
+}
+
+// UntranslatedNotice returns the notice shown on a page of language that
+// falls back to defaultLanguage, in language when known and in English
+// otherwise.
+func UntranslatedNotice(language string, defaultLanguage string) string {
+	notice, ok := untranslatedNotices[language]
+	if !ok {
+		notice = untranslatedNotices["en"]
+	}
+	return fmt.Sprintf(notice, LanguageName(defaultLanguage))
+}
+
+// WorkshopTitles are the title templates used in each language when none is
+// configured, English for languages not listed.
+var WorkshopTitles = map[string]string{
+	"de": "{{.Subject}} Workshop",
+	"en": "{{.Subject}} Workshop",
+	"es": "Taller de {{.Subject}}",
+	"fr": "Atelier {{.Subject}}",
+	"it": "Workshop di {{.Subject}}",
+	"ja": "{{.Subject}} ワークショップ",
+	"pt": "Workshop de {{.Subject}}",
+}
+
+// Localization is the workshop subject, title and homepage in one language.
+// It is also the data the title template is rendered with.
+type Localization struct {
+	Language     string
+	LanguageName string
+	Subject      string
+	Title        string
+	Homepage     string
+}
+
+// Localize resolves the subject, title and homepage of the workshop in
+// language, from the localized config of that language, then the workshop
+// wide config, then the defaults. A homepage such as home.en.md is replaced
+// by home.<language>.md when that translation exists.
+func (config *WorkshopConfig) Localize(language string) (Localization, error) {
+	return config.LocalizeIn(OsFs, language)
+}
+
+// LocalizeIn is Localize with ContentDir on fs.
+func (config *WorkshopConfig) LocalizeIn(fs afero.Fs, language string) (Localization, error) {
+	localized := config.Localized[language]
+	localization := Localization{
+		Language:     language,
+		LanguageName: LanguageName(language),
+		Subject:      firstNonEmpty(localized.WorkshopSubject, config.WorkshopSubject),
+		Homepage:     firstNonEmpty(localized.WorkshopHomepage, config.translatedHomepage(fs, language)),
+	}
+
+	titleTemplate := firstNonEmpty(localized.WorkshopTitle, config.WorkshopTitle, WorkshopTitles[language], WorkshopTitles[DefaultLanguage])
+	title, err := template.New("workshopTitle").Parse(titleTemplate)
+	if err != nil {
+		return localization, fmt.Errorf("cannot parse the workshop title %q + %+v", titleTemplate, err)
+	}
+	var rendered strings.Builder
+	if err := title.Execute(&rendered, localization); err != nil {
+		return localization, fmt.Errorf("cannot render the workshop title %q + %+v", titleTemplate, err)
+	}
+	localization.Title = rendered.String()
+	return localization, nil
+}
+
+func (config *WorkshopConfig) translatedHomepage(fs afero.Fs, language string) string {
+	base, homepageLanguage, ok := SplitLanguage(config.WorkshopHomepage)
+	if !ok || homepageLanguage == language {
+		return config.WorkshopHomepage
+	}
+	translated := base + "." + language + ".md"
+	if _, err := fs.Stat(filepath.Join(config.ContentDir, filepath.FromSlash(translated))); err == nil {
+		return translated
+	}
+	return config.WorkshopHomepage
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+const LockFileName = "dscda.lock"
+
+// LockFile records the commits the theme and content sources resolved to, so
+// later builds of the same config reproduce the same workshop.
+type LockFile struct {
+	Theme   *LockedSource `json:"theme,omitempty"`
+	Content *LockedSource `json:"content,omitempty"`
+}
+
+type LockedSource struct {
+	Location string `json:"location"`
+	Ref      string `json:"ref,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+}
+
+// ReadLockFile loads the lock file at path on fs. A missing lock file yields
+// an empty lock.
+func ReadLockFile(fs afero.Fs, path string) (*LockFile, error) {
+	var lock LockFile
+	lockFile, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return &lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(lockFile, &lock); err != nil {
+		return nil, fmt.Errorf("cannot parse %s + %+v", path, err)
+	}
+	return &lock, nil
+}
+
+func (lock *LockFile) Write(fs afero.Fs, path string) error {
+	data, err := json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fs, path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write %s + %+v", path, err)
+	}
+	return nil
+}
+
+// NewLockedSource pins source to commit.
+func NewLockedSource(source SourceConfig, commit string) *LockedSource {
+	return &LockedSource{
+		Location: source.Location,
+		Ref:      source.Ref,
+		Commit:   commit,
+	}
+}
+
+// Matches reports whether the lock entry was resolved for source. A lock
+// entry is ignored once the location or ref in config.json changes.
+func (locked *LockedSource) Matches(source SourceConfig) bool {
+	return locked != nil && locked.Location == source.Location && locked.Ref == source.Ref
+}
+
+// PinnedRef returns the ref source should be checked out at: the locked
+// commit while the lock entry still matches, otherwise the configured ref.
+func (locked *LockedSource) PinnedRef(source SourceConfig) string {
+	if locked.Matches(source) && locked.Commit != "" {
+		return locked.Commit
+	}
+	return source.Ref
+}
@@ -1,9 +1,8 @@
 package util
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"path/filepath"
+	"strings"
 )
 
 var DefaultConfig = `{
@@ -31,6 +30,49 @@ var DefaultConfig = `{
   ]
 }`
 
+var DefaultConfigYAML = `# Workshop definition, see sampleConfig.json for every option.
+workshopSubject: PACE
+workshopHomepage: ""
+modules:
+  - type: concepts
+    content:
+      - name: example-slide
+        filename: example/example-slide
+  - type: demos
+    content:
+      - name: example-demo
+        filename: example/example-demo
+`
+
+var DefaultConfigTOML = `# Workshop definition, see sampleConfig.json for every option.
+workshopSubject = "PACE"
+workshopHomepage = ""
+
+[[modules]]
+type = "concepts"
+content = [
+    { name = "example-slide", filename = "example/example-slide" },
+]
+
+[[modules]]
+type = "demos"
+content = [
+    { name = "example-demo", filename = "example/example-demo" },
+]
+`
+
+// DefaultConfigFor returns the default config in the format implied by the
+// extension of path.
+func DefaultConfigFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return DefaultConfigYAML
+	case ".toml":
+		return DefaultConfigTOML
+	}
+	return DefaultConfig
+}
+
 var DefaultManifest = `---
 applications:
 - name: my-pace-workshop
@@ -43,27 +85,210 @@ applications:
 
 var DefaultStaticFile = `guest:$apr1$oM3ne/Oz$86q6.UWNEb0Nfv3xbSiiB0`
 
+const (
+	DefaultThemeSource   = "https://github.com/datastax-cda/workshop-base"
+	DefaultContentSource = "https://github.com/datastax-cda/workshop-content"
+	DefaultContentDir    = "paceWorkshopContent"
+	DefaultGenDir        = "workshopGen"
+	DefaultOutputDir     = "publicGen"
+)
+
+// Branding of the DataStax design, used for whatever the branding config
+// leaves empty.
+const (
+	DefaultPrimaryColor   = "#4fb2a3"
+	DefaultSecondaryColor = "#09243c"
+	DefaultTagline        = "The real-time data cloud."
+	DefaultDescription    = `Leading companies build and run their most important applications with a limitless open data cloud. Learn how our 
+open data stack, tools, and methodology help you deliver exceptional real-time user experiences.`
+	DefaultFooter = `DataStax Cloud Data Architects helps companies learn how to design 
+and deploy real-time solutions. We encourage you to explore our workshops.`
+)
+
+// Values of robots.
+const (
+	RobotsAllow    = "allow"
+	RobotsDisallow = "disallow"
+)
+
+// Values of translationFallback.
+const (
+	FallbackDrop    = "drop"
+	FallbackDefault = "fallback"
+	FallbackFail    = "fail"
+)
+
+// WorkshopConfig is the workshop definition read from config.json,
+// config.yaml or config.toml. The description tags end up in the JSON Schema
+// printed by `dscda schema`.
 type WorkshopConfig struct {
-	WorkshopHomepage string `json:"workshopHomepage"`
-	WorkshopSubject  string `json:"workshopSubject"`
-	WorkshopHostname string `json:"workshopHostname"`
-	Modules          []struct {
-		Type    string          `json:"type"`
-		Content []ContentConfig `json:"content"`
-	} `json:"modules"`
+	SchemaURL           string                       `json:"$schema" description:"Path or URL of the dscda JSON Schema, only used by editors for completion."`
+	WorkshopHomepage    string                       `json:"workshopHomepage" description:"Markdown file in the content source used as the homepage instead of the default one."`
+	WorkshopSubject     string                       `json:"workshopSubject" description:"Subject of the workshop, the title becomes \"<subject> Workshop\"."`
+	WorkshopTitle       string                       `json:"workshopTitle" description:"Go template of the workshop title, e.g. \"{{.Subject}} Bootcamp\". Defaults to \"{{.Subject}} Workshop\" translated into each language."`
+	Localized           map[string]LocalizedConfig   `json:"localized" description:"Subject, title template and homepage per language, e.g. under \"es\", in place of the ones above."`
+	WorkshopHostname    string                       `json:"workshopHostname" description:"Hostname the workshop is published under, e.g. workshop.example.com, or its full URL. Sets the Hugo baseURL, canonical URLs, the sitemap and the route of the manifest.yml generated into genDir."`
+	Robots              string                       `json:"robots" description:"robots.txt policy: allow crawlers or disallow them, e.g. on staging." default:"allow" schema:"enum=allow|disallow"`
+	Environments        map[string]EnvironmentConfig `json:"environments" description:"Settings overridden per deployment environment, e.g. under \"staging\", selected with dscda build --env."`
+	ThemeSource         SourceConfig                 `json:"themeSource" description:"Where the Hugo theme comes from, the DataStax workshop-base repository by default."`
+	ContentSource       SourceConfig                 `json:"contentSource" description:"Where the workshop content comes from, the DataStax workshop-content repository by default."`
+	Languages           []string                     `json:"languages" description:"Languages the workshop is built in, the first one being the default. When empty they are discovered from the <filename>.<lang>.md files of the modules." schema:"pattern=^[a-z]{2,3}(-[a-z0-9]+)?$"`
+	TranslationFallback string                       `json:"translationFallback" description:"What happens to a page missing in a language: drop it from that language, fall back to the default language behind a \"not yet translated\" notice, or fail the build." default:"drop" schema:"enum=drop|fallback|fail"`
+	ContentDir          string                       `json:"contentDir" description:"Folder the content source is fetched into." default:"paceWorkshopContent"`
+	GenDir              string                       `json:"genDir" description:"Folder the Hugo site is generated into." default:"workshopGen"`
+	Branding            BrandingConfig               `json:"branding" description:"Colors, logos and copy of the default homepage."`
+	ModuleTypes         []ModuleTypeConfig           `json:"moduleTypes" description:"Sections modules can be placed in, in addition to the built in concepts, demos and labs, which can be redeclared to change their title, weight or icon."`
+	Modules             []ModuleConfig               `json:"modules" description:"Sections of the workshop, in menu order." schema:"required,minItems=1"`
+}
+
+// ModuleTypeConfig declares a section of the generated site. Its chapter
+// page is generated into <genDir>/content/<type>/_index.<lang>.md.
+type ModuleTypeConfig struct {
+	Type   string `json:"type" description:"Name of the section, also its folder and URL path, e.g. hackathons." schema:"required,pattern=^[a-z0-9][a-z0-9_-]*$"`
+	Title  string `json:"title" description:"Title of the section in the menu and on its chapter page, the capitalized type when empty."`
+	Weight int    `json:"weight" description:"Menu weight of the section, lighter sections are listed first."`
+	Icon   string `json:"icon" description:"Font Awesome classes of the menu icon, e.g. fas fa-flask."`
+}
+
+// DefaultModuleTypes are the module types available without being declared
+// in moduleTypes.
+var DefaultModuleTypes = []ModuleTypeConfig{
+	{Type: "concepts", Title: "Concepts", Weight: 10, Icon: "fas fa-lightbulb"},
+	{Type: "demos", Title: "Demos", Weight: 20, Icon: "fas fa-desktop"},
+	{Type: "labs", Title: "Labs", Weight: 30, Icon: "fas fa-flask"},
+}
+
+// ModuleType returns the declaration of the named module type, falling back
+// to DefaultModuleTypes. Declared reports whether moduleTypes declares it.
+func (config *WorkshopConfig) ModuleType(name string) (moduleType ModuleTypeConfig, declared bool, ok bool) {
+	for _, moduleType := range config.ModuleTypes {
+		if moduleType.Type == name {
+			return moduleType.withDefaults(), true, true
+		}
+	}
+	for _, moduleType := range DefaultModuleTypes {
+		if moduleType.Type == name {
+			return moduleType, false, true
+		}
+	}
+	return ModuleTypeConfig{}, false, false
+}
+
+// ModuleTypeNames lists the built in and declared module types.
+func (config *WorkshopConfig) ModuleTypeNames() []string {
+	var names []string
+	for _, moduleType := range DefaultModuleTypes {
+		names = append(names, moduleType.Type)
+	}
+	for _, moduleType := range config.ModuleTypes {
+		if !contains(names, moduleType.Type) {
+			names = append(names, moduleType.Type)
+		}
+	}
+	return names
+}
+
+func (moduleType ModuleTypeConfig) withDefaults() ModuleTypeConfig {
+	if moduleType.Title == "" {
+		words := strings.FieldsFunc(moduleType.Type, func(r rune) bool { return r == '-' || r == '_' })
+		for i, word := range words {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+		moduleType.Title = strings.Join(words, " ")
+	}
+	return moduleType
+}
+
+// BrandingConfig restyles the homepage generated for workshops without a
+// workshopHomepage. Files are relative to the working directory.
+type BrandingConfig struct {
+	Template       string   `json:"template" description:"Go text/template file rendering the homepage in place of the DataStax design."`
+	Logo           string   `json:"logo" description:"Logo image file, the DataStax logo when empty."`
+	CoBrandLogos   []string `json:"coBrandLogos" description:"Logo image files of partners shown next to the logo."`
+	PrimaryColor   string   `json:"primaryColor" description:"Background color of the banner." default:"#4fb2a3" schema:"pattern=^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"`
+	SecondaryColor string   `json:"secondaryColor" description:"Color of the tagline." default:"#09243c" schema:"pattern=^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"`
+	Tagline        string   `json:"tagline" description:"Headline of the banner."`
+	Description    string   `json:"description" description:"Text below the tagline."`
+	Footer         string   `json:"footer" description:"Closing line of the banner."`
+}
+
+// EnvironmentConfig overrides where and how the workshop is published in one
+// deployment environment. Empty fields keep the workshop wide value.
+type EnvironmentConfig struct {
+	WorkshopHostname string `json:"workshopHostname" description:"Hostname or URL the workshop is published under in this environment."`
+	Robots           string `json:"robots" description:"robots.txt policy in this environment." schema:"enum=allow|disallow"`
+}
+
+// LocalizedConfig holds the workshop subject, title template and homepage
+// of one language. Empty fields fall back to the workshop wide ones.
+type LocalizedConfig struct {
+	WorkshopSubject  string `json:"workshopSubject" description:"Subject of the workshop in this language."`
+	WorkshopTitle    string `json:"workshopTitle" description:"Go template of the workshop title in this language."`
+	WorkshopHomepage string `json:"workshopHomepage" description:"Markdown file in the content source used as the homepage in this language."`
+}
+
+// ModuleConfig is one section of the workshop, holding content of a single
+// module type.
+type ModuleConfig struct {
+	Type    string          `json:"type" description:"Module type, which decides the section the content is placed in: concepts, demos, labs or a type declared in moduleTypes." schema:"required,examples=concepts|demos|labs"`
+	Content []ContentConfig `json:"content" description:"Pages of the module, in menu order." schema:"required,minItems=1"`
+}
+
+// SourceConfig locates the theme or content, either as a git URL, a local
+// directory or a tarball path. Ref selects a branch, tag or commit SHA of a
+// git source and defaults to the remote's default branch.
+type SourceConfig struct {
+	Location string `json:"location" description:"Git URL, local directory or tarball (.tar.gz, .tgz, .zip, ...)."`
+	Ref      string `json:"ref" description:"Branch, tag or commit SHA of a git source, the default branch when empty."`
 }
 
 type ContentConfig struct {
-	Name     string `json:"name"`
-	Filename string `json:"filename"`
+	Name     string `json:"name" description:"Menu title of the page." schema:"required"`
+	Filename string `json:"filename" description:"Path of the page in the content source without the .<lang>.md suffix, e.g. example/example-demo." schema:"required"`
 }
 
+// DetermineConfig reads the config at path and checks its syntax, keys,
+// value types and modules. All problems found are returned together as
+// ConfigErrors; a missing file is reported as ErrConfigNotFound.
 func DetermineConfig(path string) (*WorkshopConfig, error) {
-	configFile, err := ioutil.ReadFile(path)
+	config, _, err := loadConfig(path)
 	if err != nil {
-		return nil, fmt.Errorf("config not found")
+		return nil, err
 	}
+	return config, nil
+}
+
+// NewDefaultConfig returns a config with only the default sources and
+// directories set, for commands that can run without a config.json.
+func NewDefaultConfig() *WorkshopConfig {
 	var config WorkshopConfig
-	err = json.Unmarshal(configFile, &config)
-	return &config, nil
+	config.setDefaults()
+	return &config
+}
+
+func (config *WorkshopConfig) setDefaults() {
+	if config.ThemeSource.Location == "" {
+		config.ThemeSource.Location = DefaultThemeSource
+	}
+	if config.ContentSource.Location == "" {
+		config.ContentSource.Location = DefaultContentSource
+	}
+	if config.ContentDir == "" {
+		config.ContentDir = DefaultContentDir
+	}
+	if config.GenDir == "" {
+		config.GenDir = DefaultGenDir
+	}
+	if config.TranslationFallback == "" {
+		config.TranslationFallback = FallbackDrop
+	}
+	if config.Robots == "" {
+		config.Robots = RobotsAllow
+	}
+	branding := &config.Branding
+	branding.PrimaryColor = firstNonEmpty(branding.PrimaryColor, DefaultPrimaryColor)
+	branding.SecondaryColor = firstNonEmpty(branding.SecondaryColor, DefaultSecondaryColor)
+	branding.Tagline = firstNonEmpty(branding.Tagline, DefaultTagline)
+	branding.Description = firstNonEmpty(branding.Description, DefaultDescription)
+	branding.Footer = firstNonEmpty(branding.Footer, DefaultFooter)
 }
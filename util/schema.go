@@ -0,0 +1,108 @@
+package util
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is the subset of JSON Schema draft-07 needed to describe a
+// WorkshopConfig.
+type Schema struct {
+	Draft                string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Examples             []string           `json:"examples,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+}
+
+// ConfigSchema describes WorkshopConfig as a JSON Schema. It is generated
+// from the Go types: json tags name the properties, description and default
+// tags document them and schema tags mark them required or set enum,
+// examples, minItems and pattern, which applies to the items of a list.
+// Module types are listed as examples rather than enumerated, as moduleTypes
+// can declare more.
+func ConfigSchema() *Schema {
+	schema := typeSchema(reflect.TypeOf(WorkshopConfig{}))
+	schema.Draft = SchemaDraft
+	schema.Title = "dscda workshop config"
+	schema.Description = "Definition of a workshop built by `dscda build`."
+	return schema
+}
+
+func typeSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || field.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			property := typeSchema(field.Type)
+			property.Description = field.Tag.Get("description")
+			if value, ok := field.Tag.Lookup("default"); ok {
+				property.Default = value
+			}
+			options := strings.Split(field.Tag.Get("schema"), ",")
+		tags:
+			for i, option := range options {
+				key, value, _ := cut(option, "=")
+				switch key {
+				case "required":
+					schema.Required = append(schema.Required, name)
+				case "enum":
+					property.Enum = strings.Split(value, "|")
+				case "examples":
+					property.Examples = strings.Split(value, "|")
+				case "minItems":
+					minItems, _ := strconv.Atoi(value)
+					property.MinItems = &minItems
+				case "pattern":
+					// The pattern comes last, it may contain commas.
+					_, value, _ = cut(strings.Join(options[i:], ","), "=")
+					if property.Items != nil {
+						property.Items.Pattern = value
+					} else {
+						property.Pattern = value
+					}
+					break tags
+				}
+			}
+			schema.Properties[name] = property
+		}
+		return schema
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: typeSchema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: typeSchema(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+	return &Schema{}
+}
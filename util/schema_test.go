@@ -0,0 +1,20 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeSchemaPatternWithCommas(t *testing.T) {
+	type tagged struct {
+		Code string `json:"code" schema:"pattern=^[a-z]{2,3}(,required|,minItems=1)?$"`
+	}
+	schema := typeSchema(reflect.TypeOf(tagged{}))
+	property := schema.Properties["code"]
+	if property.Pattern != "^[a-z]{2,3}(,required|,minItems=1)?$" {
+		t.Errorf("pattern = %q", property.Pattern)
+	}
+	if len(schema.Required) > 0 || property.MinItems != nil {
+		t.Errorf("options read from the pattern: required %v, minItems %v", schema.Required, property.MinItems)
+	}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvironmentEnv selects the deployment environment when --env is not given.
+const EnvironmentEnv = "DSCDA_ENV"
+
+// UseEnvironment applies the overrides of the named deployment environment.
+// An empty name keeps the config as it is.
+func (config *WorkshopConfig) UseEnvironment(name string) error {
+	if name == "" {
+		return nil
+	}
+	environment, ok := config.Environments[name]
+	if !ok {
+		var names []string
+		for known := range config.Environments {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("unknown environment %q, the config defines no environments", name)
+		}
+		return fmt.Errorf("unknown environment %q, the config defines %s", name, strings.Join(names, ", "))
+	}
+	config.WorkshopHostname = firstNonEmpty(environment.WorkshopHostname, config.WorkshopHostname)
+	config.Robots = firstNonEmpty(environment.Robots, config.Robots)
+	return nil
+}
+
+// EnvironmentName returns the environment named by --env, or else by
+// DSCDA_ENV.
+func EnvironmentName(flag string) string {
+	return firstNonEmpty(flag, os.Getenv(EnvironmentEnv))
+}
+
+// BaseURL returns the URL the site is published under, built from
+// workshopHostname with https as the default scheme, or "/" when no hostname
+// is configured.
+func (config *WorkshopConfig) BaseURL() string {
+	hostname := strings.TrimSpace(config.WorkshopHostname)
+	if hostname == "" {
+		return "/"
+	}
+	if !strings.Contains(hostname, "://") {
+		hostname = "https://" + hostname
+	}
+	return strings.TrimSuffix(hostname, "/") + "/"
+}
+
+// Route returns workshopHostname as a cf route, host and optional path
+// without a scheme.
+func (config *WorkshopConfig) Route() string {
+	route := strings.TrimSpace(config.WorkshopHostname)
+	if i := strings.Index(route, "://"); i >= 0 {
+		route = route[i+len("://"):]
+	}
+	return strings.TrimSuffix(route, "/")
+}
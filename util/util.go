@@ -2,44 +2,284 @@ package util
 
 import (
 	"compress/flate"
-	"encoding/base64"
+	"context"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
-	"strings"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/mholt/archiver"
+	cp "github.com/otiai10/copy"
 
 	git "gopkg.in/src-d/go-git.v4"
-	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 )
 
-func CloneRepo(repoPath string, destinationPath string) error {
-	data, err := base64.StdEncoding.DecodeString("Z2hwX3Q0bk83aWg3a3lUTTV1aWRyQXdwb0x4OTM1T09JdzBqRUV1Mwo=")
+// FetchSource populates destinationPath from source, which may be a local
+// directory, a tarball or a git URL. Git sources are checked out at ref and
+// their resolved commit is returned; other sources return an empty commit.
+// The source is fetched into a temporary folder next to destinationPath and
+// moved into place once complete, so a failed or canceled fetch leaves
+// nothing behind. What the fetch prints goes to out.
+func FetchSource(ctx context.Context, out io.Writer, source string, destinationPath string, ref string) (string, error) {
+	parent := filepath.Dir(filepath.Clean(destinationPath))
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", fmt.Errorf("cannot create %s + %+v", parent, err)
+	}
+	staging, err := ioutil.TempDir(parent, ".fetch-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+
+	staged := filepath.Join(staging, filepath.Base(destinationPath))
+	commit, err := fetchSource(ctx, out, source, staged, ref)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(staged, destinationPath); err != nil {
+		return "", fmt.Errorf("cannot move %s into place + %+v", destinationPath, err)
+	}
+	return commit, nil
+}
+
+func fetchSource(ctx context.Context, out io.Writer, source string, destinationPath string, ref string) (string, error) {
+	if !IsLocalSource(source) {
+		return CloneRepo(ctx, out, source, destinationPath, ref)
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		fmt.Fprintln(out, "copy "+source)
+		if err := cp.Copy(source, destinationPath); err != nil {
+			return "", fmt.Errorf("cannot copy source directory %s + %+v", source, err)
+		}
+		return "", nil
+	}
+	return "", unpackArchive(out, source, destinationPath)
+}
+
+// IsLocalSource reports whether source is a local directory or tarball
+// rather than a git URL.
+func IsLocalSource(source string) bool {
+	_, err := os.Stat(source)
+	return err == nil
+}
+
+// unpackArchive extracts a tarball or zip into destinationPath. A single
+// top-level folder, as produced by GitHub release archives, is stripped.
+func unpackArchive(out io.Writer, source string, destinationPath string) error {
+	fmt.Fprintln(out, "unpack "+source)
+
+	tmpDir, err := ioutil.TempDir(filepath.Dir(filepath.Clean(destinationPath)), ".unpack-")
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := archiver.Unarchive(source, tmpDir); err != nil {
+		return fmt.Errorf("cannot unpack source archive %s + %+v", source, err)
+	}
+
+	root := tmpDir
+	fds, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		return err
+	}
+	if len(fds) == 1 && fds[0].IsDir() {
+		root = filepath.Join(tmpDir, fds[0].Name())
+	}
+	return os.Rename(root, destinationPath)
+}
 
-	gitToken := strings.TrimSpace(string(data))
+// CloneRepo clones repoPath into destinationPath and checks out ref, which
+// may be a branch, a tag or a commit SHA. An empty ref keeps the default
+// branch. The resolved commit is returned, the clone progress goes to out.
+func CloneRepo(ctx context.Context, out io.Writer, repoPath string, destinationPath string, ref string) (string, error) {
+	auth, err := gitAuth(out, repoPath)
+	if err != nil {
+		return "", err
+	}
 
-	fmt.Println("git clone " + repoPath)
+	fmt.Fprintln(out, "git clone "+repoPath)
 
-	_, err = git.PlainClone(
-		destinationPath,
-		false,
-		&git.CloneOptions{
-			Auth: &http.BasicAuth{
-				Username: "doesnotmatter",
-				Password: gitToken,
+	var repo *git.Repository
+	err = untilDone(ctx, func() (err error) {
+		repo, err = git.PlainCloneContext(
+			ctx,
+			destinationPath,
+			false,
+			&git.CloneOptions{
+				Auth:     auth,
+				URL:      repoPath,
+				Progress: out,
 			},
-			URL:      repoPath,
-			Progress: os.Stdout,
-		},
-	)
+		)
+		return err
+	})
 
 	if err != nil {
-		return fmt.Errorf("cannot clone base git repo + %+v", err)
+		return "", fmt.Errorf("cannot clone base git repo + %+v", err)
 	}
-	return nil
+
+	hash, err := resolveRef(repo, ref)
+	if err != nil {
+		return "", err
+	}
+	if ref != "" {
+		worktree, err := repo.Worktree()
+		if err != nil {
+			return "", err
+		}
+		if err := worktree.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+			return "", fmt.Errorf("cannot check out %s + %+v", ref, err)
+		}
+	}
+	return hash.String(), nil
+}
+
+// ResolveRemoteRef returns the commit ref currently points to in the remote
+// repository at repoPath, without touching any local checkout. Only the refs
+// of the remote are listed, nothing is fetched. What it prints goes to out.
+func ResolveRemoteRef(ctx context.Context, out io.Writer, repoPath string, ref string) (string, error) {
+	auth, err := gitAuth(out, repoPath)
+	if err != nil {
+		return "", err
+	}
+	endpoint, err := transport.NewEndpoint(repoPath)
+	if err != nil {
+		return "", err
+	}
+	gitClient, err := client.NewClient(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	var refs *packp.AdvRefs
+	err = untilDone(ctx, func() error {
+		session, err := gitClient.NewUploadPackSession(endpoint, auth)
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		refs, err = session.AdvertisedReferences()
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot list the refs of %s + %+v", repoPath, err)
+	}
+
+	hash, err := resolveAdvertisedRef(refs, ref)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// resolveAdvertisedRef returns the commit ref points to among the refs a
+// remote advertises: its default branch for an empty ref, a branch, a tag,
+// peeled to its commit, or a full commit SHA.
+func resolveAdvertisedRef(refs *packp.AdvRefs, ref string) (plumbing.Hash, error) {
+	if ref == "" {
+		if refs.Head == nil {
+			return plumbing.ZeroHash, fmt.Errorf("cannot determine the default branch, set a ref")
+		}
+		return *refs.Head, nil
+	}
+	for _, name := range []string{"refs/heads/" + ref, "refs/tags/" + ref, ref} {
+		if hash, ok := refs.Peeled[name]; ok {
+			return hash, nil
+		}
+		if hash, ok := refs.References[name]; ok {
+			return hash, nil
+		}
+	}
+	if _, err := hex.DecodeString(ref); err == nil && len(ref) == 40 {
+		return plumbing.NewHash(ref), nil
+	}
+	return plumbing.ZeroHash, fmt.Errorf("cannot resolve ref %s", ref)
+}
+
+// gitResponseTimeout bounds how long a remote may take to answer. go-git
+// only passes the context to the requests transferring objects, not to the
+// one listing the refs of the remote, so a canceled fetch waits at most this
+// long on a stalled remote.
+const gitResponseTimeout = time.Minute
+
+var installGitTransport sync.Once
+
+// InstallGitTransport makes go-git talk http and https with a client bounded
+// by gitResponseTimeout. go-git keeps its transports in a global registry, so
+// the CLI installs it once at startup rather than every importer of util.
+func InstallGitTransport() {
+	installGitTransport.Do(func() {
+		httpClient := githttp.NewClient(&http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: gitResponseTimeout}).DialContext,
+			TLSHandshakeTimeout:   gitResponseTimeout,
+			ResponseHeaderTimeout: gitResponseTimeout,
+		}})
+		client.InstallProtocol("http", httpClient)
+		client.InstallProtocol("https", httpClient)
+	})
+}
+
+// untilDone runs the git operation f and returns the error of ctx when it
+// was canceled meanwhile. It waits for f to return even then, so whatever f
+// writes is only cleaned up once it stopped writing.
+func untilDone(ctx context.Context, f func() error) error {
+	err := f()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// HeadCommit returns the commit checked out in the git repository at path.
+func HeadCommit(path string) (string, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+// resolveRef looks ref up as a branch of origin first, so a fetch is picked
+// up even when a stale local branch of the same name exists, and as a commit
+// SHA, tag or local branch second. An empty ref resolves to HEAD.
+func resolveRef(repo *git.Repository, ref string) (plumbing.Hash, error) {
+	if ref == "" {
+		head, err := repo.Head()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return head.Hash(), nil
+	}
+	for _, rev := range []string{git.DefaultRemoteName + "/" + ref, ref} {
+		if hash, err := repo.ResolveRevision(plumbing.Revision(rev)); err == nil {
+			return *hash, nil
+		}
+	}
+	return plumbing.ZeroHash, fmt.Errorf("cannot resolve ref %s", ref)
 }
 
 func RemoveGitMetadata(destinationPath string) error {
@@ -0,0 +1,550 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var ErrConfigNotFound = errors.New("config not found")
+
+// ConfigError is a problem found in a config file, located by line and
+// column. A zero line means the problem is not tied to a position.
+type ConfigError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// ConfigErrors collects every problem found in one pass over a config.
+type ConfigErrors []ConfigError
+
+func (errs ConfigErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+type position struct {
+	Line   int
+	Column int
+}
+
+type nodeKind int
+
+const (
+	nullNode nodeKind = iota
+	objectNode
+	arrayNode
+	stringNode
+	numberNode
+	boolNode
+)
+
+func (kind nodeKind) String() string {
+	return [...]string{"null", "object", "array", "string", "number", "boolean"}[kind]
+}
+
+// configNode is a parsed config value that remembers where it was written,
+// so checks running after decoding can still point at the offending line.
+type configNode struct {
+	position
+	kind   nodeKind
+	fields []configField
+	items  []*configNode
+	value  interface{}
+}
+
+type configField struct {
+	position
+	name  string
+	value *configNode
+}
+
+func (node *configNode) field(name string) *configNode {
+	if node == nil {
+		return nil
+	}
+	for _, field := range node.fields {
+		if field.name == name {
+			return field.value
+		}
+	}
+	return nil
+}
+
+func (node *configNode) item(i int) *configNode {
+	if node == nil || i >= len(node.items) {
+		return nil
+	}
+	return node.items[i]
+}
+
+func (node *configNode) pos() position {
+	if node == nil {
+		return position{}
+	}
+	return node.position
+}
+
+// configParser builds a configNode tree from JSON, tracking the offset of
+// every token so errors can be reported by line and column.
+type configParser struct {
+	file string
+	data []byte
+	dec  *json.Decoder
+	errs ConfigErrors
+}
+
+func parseJSONConfig(file string, data []byte) (*configNode, ConfigErrors) {
+	parser := &configParser{file: file, data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	parser.dec.UseNumber()
+
+	root, err := parser.parseValue()
+	if err != nil {
+		return nil, append(parser.errs, parser.syntaxError(err))
+	}
+	if _, err := parser.dec.Token(); err != io.EOF {
+		pos := parser.nextPosition()
+		return nil, append(parser.errs, parser.errorAt(pos, "unexpected data after the end of the config"))
+	}
+	return root, parser.errs
+}
+
+func (parser *configParser) parseValue() (*configNode, error) {
+	pos := parser.nextPosition()
+	token, err := parser.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	node := &configNode{position: pos}
+
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '{':
+			node.kind = objectNode
+			seen := map[string]position{}
+			for parser.dec.More() {
+				keyPos := parser.nextPosition()
+				key, err := parser.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				name, _ := key.(string)
+				value, err := parser.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				if first, ok := seen[name]; ok {
+					parser.errs = append(parser.errs, parser.errorAt(keyPos, "duplicate key %q, first set at line %d", name, first.Line))
+				}
+				seen[name] = keyPos
+				node.fields = append(node.fields, configField{position: keyPos, name: name, value: value})
+			}
+		case '[':
+			node.kind = arrayNode
+			for parser.dec.More() {
+				item, err := parser.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, item)
+			}
+		default:
+			return nil, &json.SyntaxError{Offset: parser.dec.InputOffset()}
+		}
+		if _, err := parser.dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		node.kind, node.value = stringNode, token
+	case json.Number:
+		node.kind, node.value = numberNode, token
+	case bool:
+		node.kind, node.value = boolNode, token
+	case nil:
+		node.kind = nullNode
+	}
+	return node, nil
+}
+
+// nextPosition skips the whitespace and separators the decoder has not
+// consumed yet and returns the position of the next token.
+func (parser *configParser) nextPosition() position {
+	offset := int(parser.dec.InputOffset())
+	for offset < len(parser.data) && strings.IndexByte(" \t\r\n,:", parser.data[offset]) >= 0 {
+		offset++
+	}
+	return offsetPosition(parser.data, offset)
+}
+
+func (parser *configParser) syntaxError(err error) ConfigError {
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return parser.errorAt(offsetPosition(parser.data, int(syntaxErr.Offset)-1), "syntax error: %s", syntaxErr.Error())
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		return parser.errorAt(offsetPosition(parser.data, len(parser.data)), "syntax error: unexpected end of file")
+	}
+	return parser.errorAt(parser.nextPosition(), "syntax error: %s", err.Error())
+}
+
+func (parser *configParser) errorAt(pos position, format string, args ...interface{}) ConfigError {
+	return ConfigError{File: parser.file, Line: pos.Line, Column: pos.Column, Message: fmt.Sprintf(format, args...)}
+}
+
+func offsetPosition(data []byte, offset int) position {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return position{Line: line, Column: column}
+}
+
+// configValidator checks a configNode tree against the config schema and
+// against the rules build relies on.
+type configValidator struct {
+	file string
+	errs ConfigErrors
+}
+
+func (v *configValidator) errorf(pos position, format string, args ...interface{}) {
+	v.errs = append(v.errs, ConfigError{File: v.file, Line: pos.Line, Column: pos.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkSchema walks node alongside the JSON Schema generated for the config,
+// reporting unknown or missing keys, empty lists, values outside an enum and
+// values of the wrong kind by their dotted path, e.g.
+// modules[1].content[0].filename.
+func (v *configValidator) checkSchema(node *configNode, schema *Schema, path string) {
+	if node == nil || node.kind == nullNode {
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		if node.kind != objectNode {
+			v.errorf(node.position, "%s must be an object, not %s", describePath(path), node.kind)
+			return
+		}
+		for _, required := range schema.Required {
+			if node.field(required) == nil {
+				v.errorf(node.position, "%s is missing required key %q", describePath(path), required)
+			}
+		}
+		for _, field := range node.fields {
+			property, ok := schema.Properties[field.name]
+			if !ok {
+				property, ok = schema.AdditionalProperties.(*Schema)
+			}
+			if !ok {
+				v.errorf(field.position, "unknown key %q in %s", field.name, describePath(path))
+				continue
+			}
+			v.checkSchema(field.value, property, joinPath(path, field.name))
+		}
+	case "array":
+		if node.kind != arrayNode {
+			v.errorf(node.position, "%s must be an array, not %s", describePath(path), node.kind)
+			return
+		}
+		if schema.MinItems != nil && len(node.items) < *schema.MinItems {
+			v.errorf(node.position, "%s must not be empty", describePath(path))
+		}
+		for i, item := range node.items {
+			v.checkSchema(item, schema.Items, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "string":
+		if node.kind != stringNode {
+			v.errorf(node.position, "%s must be a string, not %s", describePath(path), node.kind)
+			return
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, node.value.(string)) {
+			v.errorf(node.position, "%s must be one of %s, not %q", describePath(path), strings.Join(schema.Enum, ", "), node.value)
+		}
+		if schema.Pattern != "" && !regexp.MustCompile(schema.Pattern).MatchString(node.value.(string)) {
+			v.errorf(node.position, "%s must match %s, not %q", describePath(path), schema.Pattern, node.value)
+		}
+	case "boolean":
+		if node.kind != boolNode {
+			v.errorf(node.position, "%s must be a boolean, not %s", describePath(path), node.kind)
+		}
+	case "integer":
+		if node.kind != numberNode {
+			v.errorf(node.position, "%s must be a number, not %s", describePath(path), node.kind)
+		} else if _, err := node.value.(json.Number).Int64(); err != nil {
+			v.errorf(node.position, "%s must be a whole number", describePath(path))
+		}
+	case "number":
+		if node.kind != numberNode {
+			v.errorf(node.position, "%s must be a number, not %s", describePath(path), node.kind)
+		}
+	}
+}
+
+// checkTitles reports workshop title templates that do not render.
+func (v *configValidator) checkTitles(config *WorkshopConfig, root *configNode) {
+	if config.WorkshopTitle != "" {
+		if _, err := config.Localize(DefaultLanguage); err != nil {
+			v.errorf(root.field("workshopTitle").pos(), "%s", err)
+		}
+	}
+	for _, language := range sortedKeys(config.Localized) {
+		if config.Localized[language].WorkshopTitle == "" {
+			continue
+		}
+		if _, err := config.Localize(language); err != nil {
+			v.errorf(root.field("localized").field(language).field("workshopTitle").pos(), "%s", err)
+		}
+	}
+}
+
+func sortedKeys(localized map[string]LocalizedConfig) []string {
+	var keys []string
+	for key := range localized {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkModules reports what the schema cannot express: module types declared
+// twice, modules of undeclared types and content entries that would generate
+// the same page.
+func (v *configValidator) checkModules(config *WorkshopConfig, root *configNode) {
+	moduleTypesNode := root.field("moduleTypes")
+	declared := map[string]position{}
+	for i, moduleType := range config.ModuleTypes {
+		pos := moduleTypesNode.item(i).pos()
+		if typeNode := moduleTypesNode.item(i).field("type"); typeNode != nil {
+			pos = typeNode.position
+		}
+		if first, ok := declared[moduleType.Type]; ok {
+			v.errorf(pos, "module type %q is already declared at line %d", moduleType.Type, first.Line)
+			continue
+		}
+		declared[moduleType.Type] = pos
+	}
+
+	modulesNode := root.field("modules")
+	pages := map[string]position{}
+	for i, module := range config.Modules {
+		if _, _, ok := config.ModuleType(module.Type); !ok && module.Type != "" {
+			pos := modulesNode.item(i).pos()
+			if typeNode := modulesNode.item(i).field("type"); typeNode != nil {
+				pos = typeNode.position
+			}
+			v.errorf(pos, "module type %q is not declared in moduleTypes, known types are %s", module.Type, strings.Join(config.ModuleTypeNames(), ", "))
+		}
+		contentNode := modulesNode.item(i).field("content")
+		for j, content := range module.Content {
+			if content.Filename == "" {
+				continue
+			}
+			entryNode := contentNode.item(j)
+			page := module.Type + "/" + path.Base(content.Filename)
+			pos := entryNode.pos()
+			if filenameNode := entryNode.field("filename"); filenameNode != nil {
+				pos = filenameNode.position
+			}
+			if first, ok := pages[page]; ok {
+				v.errorf(pos, "duplicate content entry %q in %s, first listed at line %d", content.Filename, module.Type, first.Line)
+				continue
+			}
+			pages[page] = pos
+		}
+	}
+}
+
+// checkBranding verifies that the homepage template parses and that the
+// template and logo files exist.
+func (v *configValidator) checkBranding(config *WorkshopConfig, root *configNode) {
+	brandingNode := root.field("branding")
+	branding := config.Branding
+	if branding.Template != "" {
+		if data, err := ioutil.ReadFile(branding.Template); err != nil {
+			v.errorf(brandingNode.field("template").pos(), "homepage template %s not found", branding.Template)
+		} else if _, err := template.New("homepage").Parse(string(data)); err != nil {
+			v.errorf(brandingNode.field("template").pos(), "cannot parse homepage template %s: %v", branding.Template, err)
+		}
+	}
+	if branding.Logo != "" {
+		if _, err := os.Stat(branding.Logo); err != nil {
+			v.errorf(brandingNode.field("logo").pos(), "logo %s not found", branding.Logo)
+		}
+	}
+	for i, logo := range branding.CoBrandLogos {
+		if _, err := os.Stat(logo); err != nil {
+			v.errorf(brandingNode.field("coBrandLogos").item(i).pos(), "co-brand logo %s not found", logo)
+		}
+	}
+}
+
+// checkContent verifies that the homepage and every content entry exist in
+// contentDir. A content entry exists when at least one of its
+// <filename>.<lang>.md translations does.
+func (v *configValidator) checkContent(config *WorkshopConfig, root *configNode, contentDir string) {
+	if config.WorkshopHomepage != "" {
+		if _, err := os.Stat(filepath.Join(contentDir, config.WorkshopHomepage)); err != nil {
+			v.errorf(root.field("workshopHomepage").pos(), "homepage %s not found in %s", config.WorkshopHomepage, contentDir)
+		}
+	}
+	for _, language := range sortedKeys(config.Localized) {
+		homepage := config.Localized[language].WorkshopHomepage
+		if homepage == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(contentDir, homepage)); err != nil {
+			v.errorf(root.field("localized").field(language).field("workshopHomepage").pos(), "%s homepage %s not found in %s", language, homepage, contentDir)
+		}
+	}
+
+	modulesNode := root.field("modules")
+	for i, module := range config.Modules {
+		contentNode := modulesNode.item(i).field("content")
+		for j, content := range module.Content {
+			if content.Filename == "" {
+				continue
+			}
+			matches, _ := filepath.Glob(filepath.Join(contentDir, filepath.FromSlash(content.Filename)) + ".*.md")
+			if len(matches) == 0 {
+				entryNode := contentNode.item(j)
+				pos := entryNode.pos()
+				if filenameNode := entryNode.field("filename"); filenameNode != nil {
+					pos = filenameNode.position
+				}
+				v.errorf(pos, "content file %s.<lang>.md not found in %s", content.Filename, contentDir)
+			}
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "the config"
+	}
+	return path
+}
+
+// loadConfig reads, parses and structurally validates the config at
+// configPath, returning the decoded config along with its node tree.
+func loadConfig(configPath string) (*WorkshopConfig, *configNode, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrConfigNotFound, configPath)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read config %s: %v", configPath, err)
+	}
+
+	root, errs := parseConfigNodes(configPath, data)
+	if root == nil {
+		return nil, nil, errs
+	}
+
+	validator := &configValidator{file: configPath, errs: errs}
+	validator.checkSchema(root, ConfigSchema(), "")
+
+	// Unknown keys do not stop decoding, so the module rules are still
+	// checked and reported in the same pass; mistyped values do.
+	var config WorkshopConfig
+	if err := decodeConfigNodes(root, &config); err != nil {
+		if len(validator.errs) == 0 {
+			validator.errorf(position{}, "%s", err.Error())
+		}
+		return nil, nil, validator.errs
+	}
+	validator.checkModules(&config, root)
+	validator.checkTitles(&config, root)
+	if len(validator.errs) > 0 {
+		sort.SliceStable(validator.errs, func(i, j int) bool { return validator.errs[i].Line < validator.errs[j].Line })
+		return nil, nil, validator.errs
+	}
+	config.setDefaults()
+	return &config, root, nil
+}
+
+// decodeConfigNodes maps a node tree of any format onto config through its
+// json tags, so JSON, YAML and TOML share one set of field names.
+func decodeConfigNodes(root *configNode, config *WorkshopConfig) error {
+	data, err := json.Marshal(root.interfaceValue())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, config)
+}
+
+// ValidateConfig runs every check on the config at configPath, including
+// that the referenced content exists, and returns all problems found. When
+// the content has not been fetched yet the content checks are skipped and
+// a note saying so is returned.
+func ValidateConfig(configPath string) (*WorkshopConfig, string, error) {
+	config, root, err := loadConfig(configPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	validator := &configValidator{file: configPath}
+	validator.checkBranding(config, root)
+
+	var note string
+	contentDir := config.ContentDir
+	if _, err := os.Stat(contentDir); err != nil {
+		if info, err := os.Stat(config.ContentSource.Location); err == nil && info.IsDir() {
+			contentDir = config.ContentSource.Location
+		} else {
+			note = fmt.Sprintf("content files not checked, %s has not been fetched yet (run `dscda init`)", config.ContentDir)
+			contentDir = ""
+		}
+	}
+	if contentDir != "" {
+		validator.checkContent(config, root, contentDir)
+	}
+
+	if len(validator.errs) > 0 {
+		sort.SliceStable(validator.errs, func(i, j int) bool { return validator.errs[i].Line < validator.errs[j].Line })
+		return config, "", validator.errs
+	}
+	return config, note, nil
+}
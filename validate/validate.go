@@ -0,0 +1,31 @@
+package validate
+
+import (
+	"fmt"
+
+	"workshop-builder/util"
+)
+
+func ValidateCmd(configPath string) error {
+	configPath, err := util.FindConfig(configPath)
+	if err != nil {
+		return util.WithExitCode(util.ExitConfig, err)
+	}
+	_, note, err := util.ValidateConfig(configPath)
+	if err != nil {
+		errs, ok := err.(util.ConfigErrors)
+		if !ok {
+			return util.WithExitCode(util.ExitConfig, err)
+		}
+		fmt.Println(err.Error())
+		if len(errs) == 1 {
+			return util.WithExitCode(util.ExitConfig, fmt.Errorf("1 problem found"))
+		}
+		return util.WithExitCode(util.ExitConfig, fmt.Errorf("%d problems found", len(errs)))
+	}
+	if note != "" {
+		fmt.Println("Note: " + note)
+	}
+	fmt.Println(configPath + " is valid")
+	return nil
+}